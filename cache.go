@@ -0,0 +1,161 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// httpCache caches responses of release listings (such as the index.json
+// of releases.hashicorp.com) on disk. Fresh entries are used without any
+// request, stale entries are revalidated using ETag and Last-Modified. If
+// revalidation fails, e.g. when offline, the stale entry is used.
+//
+// A nil *httpCache fetches every request without caching.
+type httpCache struct {
+	dir string
+	ttl time.Duration
+	// now is replaced in tests
+	now func() time.Time
+}
+
+type cacheEntry struct {
+	URL          string    `json:"url"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	FetchedAt    time.Time `json:"fetched_at"`
+}
+
+func newHTTPCache(dir string, ttl time.Duration) *httpCache {
+	return &httpCache{dir: dir, ttl: ttl, now: time.Now}
+}
+
+func (c *httpCache) paths(url string) (string, string) {
+	hash := sha256.Sum256([]byte(url))
+	name := hex.EncodeToString(hash[:])
+	return filepath.Join(c.dir, name+".json"), filepath.Join(c.dir, name+".body")
+}
+
+func (c *httpCache) load(url string) (*cacheEntry, []byte) {
+	metaFile, bodyFile := c.paths(url)
+	data, err := os.ReadFile(metaFile)
+	if err != nil {
+		return nil, nil
+	}
+	entry := &cacheEntry{}
+	if err := json.Unmarshal(data, entry); err != nil || entry.URL != url {
+		return nil, nil
+	}
+	body, err := os.ReadFile(bodyFile)
+	if err != nil {
+		return nil, nil
+	}
+	return entry, body
+}
+
+func (c *httpCache) store(entry *cacheEntry, body []byte) error {
+	if err := createDir(c.dir); err != nil {
+		return err
+	}
+	metaFile, bodyFile := c.paths(entry.URL)
+	tmp := bodyFile + ".tmp"
+	if err := os.WriteFile(tmp, body, 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, bodyFile); err != nil {
+		return err
+	}
+	return writeJSONFile(metaFile, entry)
+}
+
+// get returns the body of url, using the cache where possible.
+func (c *httpCache) get(url string, header http.Header) ([]byte, error) {
+	if c == nil {
+		return fetchBody(url, header)
+	}
+
+	entry, cached := c.load(url)
+	if entry != nil && c.now().Sub(entry.FetchedAt) < c.ttl {
+		return cached, nil
+	}
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if entry != nil {
+		if entry.ETag != "" {
+			req.Header.Set("If-None-Match", entry.ETag)
+		}
+		if entry.LastModified != "" {
+			req.Header.Set("If-Modified-Since", entry.LastModified)
+		}
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return c.fallback(url, entry, cached, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && entry != nil {
+		entry.FetchedAt = c.now()
+		if err := c.store(entry, cached); err != nil {
+			logger.Warn("could not update cache", "url", url, "error", err.Error())
+		}
+		return cached, nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		err := fmt.Errorf("could not download %s: %s", url, resp.Status)
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, err
+		}
+		// rate limits (403, 429) and server errors are temporary, unlike a
+		// listing which is gone
+		return c.fallback(url, entry, cached, err)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return c.fallback(url, entry, cached, err)
+	}
+
+	entry = &cacheEntry{
+		URL:          url,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		FetchedAt:    c.now(),
+	}
+	if err := c.store(entry, body); err != nil {
+		logger.Warn("could not cache", "url", url, "error", err.Error())
+	}
+	return body, nil
+}
+
+// fallback returns a stale cache entry if there is one, and err otherwise.
+func (c *httpCache) fallback(url string, entry *cacheEntry, cached []byte, err error) ([]byte, error) {
+	if entry == nil {
+		return nil, err
+	}
+	age := c.now().Sub(entry.FetchedAt).Round(time.Minute)
+	logger.Warn(fmt.Sprintf("%s, using cached copy from %s ago which might be outdated", err.Error(), age))
+	return cached, nil
+}
+
+func fetchBody(url string, header http.Header) ([]byte, error) {
+	resp, err := httpGet(url, header)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
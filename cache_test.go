@@ -0,0 +1,156 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHTTPCache(t *testing.T) {
+	requests := 0
+	lastIfNoneMatch := ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		lastIfNoneMatch = r.Header.Get("If-None-Match")
+		switch r.URL.Path {
+		case "/index.json":
+			if lastIfNoneMatch == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("ETag", `"v1"`)
+			w.Write([]byte("index"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	c := newHTTPCache(t.TempDir(), time.Hour)
+	c.now = func() time.Time { return now }
+	url := server.URL + "/index.json"
+
+	// first request populates the cache
+	body, err := c.get(url, nil)
+	if err != nil || string(body) != "index" {
+		t.Fatalf("get() = %q, %v", body, err)
+	}
+	if requests != 1 {
+		t.Errorf("expected 1 request, got %d", requests)
+	}
+
+	// fresh entries are served without a request
+	now = now.Add(30 * time.Minute)
+	body, err = c.get(url, nil)
+	if err != nil || string(body) != "index" {
+		t.Fatalf("get() = %q, %v", body, err)
+	}
+	if requests != 1 {
+		t.Errorf("expected no further request for fresh entry, got %d requests", requests)
+	}
+
+	// stale entries are revalidated
+	now = now.Add(time.Hour)
+	body, err = c.get(url, nil)
+	if err != nil || string(body) != "index" {
+		t.Fatalf("get() = %q, %v", body, err)
+	}
+	if requests != 2 {
+		t.Errorf("expected revalidation request, got %d requests", requests)
+	}
+	if lastIfNoneMatch != `"v1"` {
+		t.Errorf("If-None-Match = %q, want %q", lastIfNoneMatch, `"v1"`)
+	}
+
+	// revalidation resets the age of the entry
+	now = now.Add(30 * time.Minute)
+	if _, err := c.get(url, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if requests != 2 {
+		t.Errorf("expected no further request after revalidation, got %d requests", requests)
+	}
+
+	// not found is an error, not cached
+	if _, err := c.get(server.URL+"/missing.json", nil); err == nil {
+		t.Error("expected error for missing file, got nil")
+	}
+
+	// stale entries are used when offline
+	server.Close()
+	now = now.Add(24 * time.Hour)
+	body, err = c.get(url, nil)
+	if err != nil || string(body) != "index" {
+		t.Errorf("expected stale entry when offline, got %q, %v", body, err)
+	}
+
+	// without a cached entry, being offline is an error
+	if _, err := c.get(server.URL+"/other.json", nil); err == nil {
+		t.Error("expected error when offline without cache, got nil")
+	}
+}
+
+func TestHTTPCacheFallback(t *testing.T) {
+	tests := []struct {
+		status      int
+		expectError bool
+	}{
+		{status: http.StatusForbidden},
+		{status: http.StatusTooManyRequests},
+		{status: http.StatusInternalServerError},
+		{status: http.StatusNotFound, expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			status := http.StatusOK
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(status)
+				w.Write([]byte("index"))
+			}))
+			defer server.Close()
+
+			now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+			c := newHTTPCache(t.TempDir(), time.Hour)
+			c.now = func() time.Time { return now }
+			url := server.URL + "/index.json"
+
+			if _, err := c.get(url, nil); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			status = tt.status
+			now = now.Add(2 * time.Hour)
+			body, err := c.get(url, nil)
+			if tt.expectError {
+				if err == nil {
+					t.Error("expected error, got nil")
+				}
+				return
+			}
+			if err != nil || string(body) != "index" {
+				t.Errorf("expected cached entry, got %q, %v", body, err)
+			}
+
+			// without a cached entry, the status is an error
+			if _, err := c.get(server.URL+"/other.json", nil); err == nil {
+				t.Error("expected error without cache, got nil")
+			}
+		})
+	}
+}
+
+func TestHTTPCacheNil(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("uncached"))
+	}))
+	defer server.Close()
+
+	var c *httpCache
+	body, err := c.get(server.URL, nil)
+	if err != nil || string(body) != "uncached" {
+		t.Errorf("get() = %q, %v", body, err)
+	}
+}
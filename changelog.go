@@ -0,0 +1,202 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	ver "github.com/hashicorp/go-version"
+)
+
+// ReleaseNotes returns the release notes of v from the first source that
+// provides them.
+func (tf *Terraform) ReleaseNotes(v *ver.Version) (string, error) {
+	errs := []string{}
+	for _, s := range tf.sources {
+		rs, ok := s.(releaseNotesSource)
+		if !ok {
+			continue
+		}
+		notes, err := rs.ReleaseNotes(tf.tool, v)
+		if err == nil {
+			return notes, nil
+		}
+		if errors.Is(err, errNoReleaseNotes) {
+			continue
+		}
+		errs = append(errs, fmt.Sprintf("%s: %s", s, err.Error()))
+	}
+	if len(errs) == 0 {
+		return "", fmt.Errorf("no release source provides release notes for %s", tf.tool.Name)
+	}
+	return "", fmt.Errorf("could not fetch release notes of %s %s:\n  %s", tf.tool.Name, v.String(), strings.Join(errs, "\n  "))
+}
+
+// isVersionRange reports whether the argument s is meant as a range such as
+// 1.5.7..1.6.2 rather than a directory such as .. or ../live.
+func isVersionRange(s string) bool {
+	a, b, ok := strings.Cut(s, "..")
+	return ok && a != "" && b != "" && !strings.ContainsAny(s, `/\`)
+}
+
+// parseVersionRange parses a range such as 1.5.7..1.6.2.
+func parseVersionRange(s string) (*ver.Version, *ver.Version, error) {
+	a, b, ok := strings.Cut(s, "..")
+	if !ok {
+		return nil, nil, fmt.Errorf("invalid range '%s', use <from>..<to>", s)
+	}
+	from, err := ver.NewVersion(a)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid version '%s': %w", a, err)
+	}
+	to, err := ver.NewVersion(b)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid version '%s': %w", b, err)
+	}
+	if to.LessThan(from) {
+		return nil, nil, fmt.Errorf("invalid range '%s': %s is older than %s", s, b, a)
+	}
+	return from, to, nil
+}
+
+// releasesBetween returns the releases newer than from up to and including
+// to. Prereleases are skipped unless prerelease is set.
+func releasesBetween(releases []Release, from, to *ver.Version, prerelease bool) []Release {
+	out := []Release{}
+	for _, r := range releases {
+		if r.Prerelease && !prerelease {
+			continue
+		}
+		if r.Version.GreaterThan(from) && !r.Version.GreaterThan(to) {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// changelogSection returns the section of a CHANGELOG.md describing v. A
+// section starts with a level 2 heading such as `## 1.6.2 (October 18, 2023)`.
+func changelogSection(changelog string, v *ver.Version) (string, bool) {
+	out := []string{}
+	found := false
+	for _, line := range strings.Split(changelog, "\n") {
+		if heading, ok := strings.CutPrefix(line, "## "); ok {
+			if found {
+				break
+			}
+			fields := strings.Fields(heading)
+			if len(fields) > 0 {
+				if hv, err := ver.NewVersion(fields[0]); err == nil && hv.Equal(v) {
+					found = true
+				}
+			}
+			continue
+		}
+		if found {
+			out = append(out, line)
+		}
+	}
+	return strings.TrimSpace(strings.Join(out, "\n")), found
+}
+
+// githubRawURL turns a link to a file on github.com into a link to its raw
+// content. Other URLs are returned unchanged.
+func githubRawURL(url string) string {
+	rest, ok := strings.CutPrefix(url, "https://github.com/")
+	if !ok {
+		return url
+	}
+	// owner, repository, "blob", ref and path
+	parts := strings.SplitN(rest, "/", 4)
+	if len(parts) < 4 || parts[2] != "blob" {
+		return url
+	}
+	return fmt.Sprintf("https://raw.githubusercontent.com/%s/%s/%s", parts[0], parts[1], parts[3])
+}
+
+// importantHeading matches the headings of sections which need attention
+// before upgrading.
+var importantHeading = regexp.MustCompile(`(?i)breaking|upgrade notes`)
+
+// isSectionHeading reports whether line starts a section of release notes:
+// either a markdown heading or a line like `BREAKING CHANGES:` as used by
+// the HashiCorp changelogs.
+func isSectionHeading(line string) bool {
+	line = strings.TrimSpace(line)
+	if strings.HasPrefix(line, "#") {
+		return true
+	}
+	line = strings.Trim(line, "*_")
+	return strings.HasSuffix(line, ":") && strings.ToUpper(line) == line && strings.ToLower(line) != line
+}
+
+// highlightNotes passes all lines of BREAKING and UPGRADE NOTES sections
+// through mark and reports whether there were any.
+func highlightNotes(notes string, mark func(string) string) (string, bool) {
+	lines := strings.Split(notes, "\n")
+	important, found := false, false
+	for i, line := range lines {
+		if isSectionHeading(line) {
+			important = importantHeading.MatchString(line)
+			found = found || important
+		}
+		if important && strings.TrimSpace(line) != "" {
+			lines[i] = mark(line)
+		}
+	}
+	return strings.Join(lines, "\n"), found
+}
+
+// releaseNotes are the notes of a single release shown by `wtf changelog`.
+type releaseNotes struct {
+	Version *ver.Version
+	Date    time.Time
+	Notes   string
+	Err     error
+}
+
+// printChangelog prints the notes of every release. If color is set,
+// important sections are printed in red, otherwise their lines are
+// prefixed with `!!`.
+func printChangelog(w io.Writer, notes []releaseNotes, color bool) {
+	mark := func(line string) string {
+		return "!! " + line
+	}
+	if color {
+		mark = func(line string) string {
+			return "\033[1;31m" + line + "\033[0m"
+		}
+	}
+
+	important := []string{}
+	for _, n := range notes {
+		heading := "## " + n.Version.String()
+		if !n.Date.IsZero() {
+			heading = fmt.Sprintf("%s (%s)", heading, n.Date.Format("2006-01-02"))
+		}
+		fmt.Fprintf(w, "%s\n\n", heading)
+		if n.Err != nil {
+			fmt.Fprintf(w, "%s\n\n", n.Err.Error())
+			continue
+		}
+		text, found := highlightNotes(n.Notes, mark)
+		if found {
+			important = append(important, n.Version.String())
+		}
+		fmt.Fprintf(w, "%s\n\n", strings.TrimSpace(text))
+	}
+
+	if len(important) > 0 {
+		fmt.Fprintln(w, mark("Breaking changes or upgrade notes in: "+strings.Join(important, ", ")))
+	}
+}
+
+// isTerminal reports whether f is a terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
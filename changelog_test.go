@@ -0,0 +1,288 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testChangelog = `## 1.6.2 (October 18, 2023)
+
+BUG FIXES:
+
+* fix the thing
+
+## 1.6.1 (October 10, 2023)
+
+ENHANCEMENTS:
+
+* a flag
+
+## 1.6.0 (October 4, 2023)
+
+UPGRADE NOTES:
+
+* ` + "`terraform test`" + ` has been rewritten
+
+NEW FEATURES:
+
+* tests
+`
+
+func TestParseVersionRange(t *testing.T) {
+	tests := []struct {
+		input       string
+		expected    string
+		expectError bool
+	}{
+		{input: "1.5.7..1.6.2", expected: "1.5.7 1.6.2"},
+		{input: "v1.5.7..v1.6.0-rc1", expected: "1.5.7 1.6.0-rc1"},
+		{input: "1.5.7", expectError: true},
+		{input: "1.5.7..latest", expectError: true},
+		{input: "1.6.2..1.5.7", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			from, to, err := parseVersionRange(tt.input)
+			if tt.expectError {
+				if err == nil {
+					t.Error("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if s := from.String() + " " + to.String(); s != tt.expected {
+				t.Errorf("parseVersionRange() = %q, want %q", s, tt.expected)
+			}
+		})
+	}
+}
+
+func TestIsVersionRange(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{input: "1.5.7..1.6.2", expected: true},
+		{input: "1.6.2..1.5.7", expected: true},
+		{input: "1.5.7..latest", expected: true},
+		{input: "..", expected: false},
+		{input: "../live", expected: false},
+		{input: "..\\live", expected: false},
+		{input: "1.5.7..", expected: false},
+		{input: "..1.6.2", expected: false},
+		{input: "live/1.5..1.6", expected: false},
+		{input: "1.5.7", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if got := isVersionRange(tt.input); got != tt.expected {
+				t.Errorf("isVersionRange(%q) = %v, want %v", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestReleasesBetween(t *testing.T) {
+	releases := []Release{}
+	for _, v := range mustVersions(t, "1.5.6", "1.5.7", "1.6.0-rc1", "1.6.0", "1.6.1", "1.6.2", "1.7.0") {
+		releases = append(releases, newRelease(v))
+	}
+	v := mustVersions(t, "1.5.7", "1.6.2")
+
+	result := []string{}
+	for _, r := range releasesBetween(releases, v[0], v[1], false) {
+		result = append(result, r.Version.String())
+	}
+	if s := strings.Join(result, " "); s != "1.6.0 1.6.1 1.6.2" {
+		t.Errorf("releasesBetween() = %q", s)
+	}
+	if n := len(releasesBetween(releases, v[0], v[1], true)); n != 4 {
+		t.Errorf("expected 4 releases including prereleases, got %d", n)
+	}
+}
+
+func TestChangelogSection(t *testing.T) {
+	tests := []struct {
+		version  string
+		expected string
+		found    bool
+	}{
+		{version: "1.6.2", expected: "BUG FIXES:\n\n* fix the thing", found: true},
+		{version: "1.6.0", expected: "UPGRADE NOTES:\n\n* `terraform test` has been rewritten\n\nNEW FEATURES:\n\n* tests", found: true},
+		{version: "1.5.7"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			section, found := changelogSection(testChangelog, mustVersions(t, tt.version)[0])
+			if found != tt.found || section != tt.expected {
+				t.Errorf("changelogSection() = %q, %v, want %q, %v", section, found, tt.expected, tt.found)
+			}
+		})
+	}
+}
+
+func TestGithubRawURL(t *testing.T) {
+	tests := []struct {
+		url      string
+		expected string
+	}{
+		{
+			url:      "https://github.com/hashicorp/terraform/blob/v1.6.2/CHANGELOG.md",
+			expected: "https://raw.githubusercontent.com/hashicorp/terraform/v1.6.2/CHANGELOG.md",
+		},
+		{
+			url:      "https://github.com/hashicorp/terraform/releases",
+			expected: "https://github.com/hashicorp/terraform/releases",
+		},
+		{
+			url:      "https://example.com/CHANGELOG.md",
+			expected: "https://example.com/CHANGELOG.md",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			if u := githubRawURL(tt.url); u != tt.expected {
+				t.Errorf("githubRawURL() = %q, want %q", u, tt.expected)
+			}
+		})
+	}
+}
+
+func TestHighlightNotes(t *testing.T) {
+	mark := func(line string) string { return "!! " + line }
+
+	tests := []struct {
+		name     string
+		notes    string
+		expected string
+		found    bool
+	}{
+		{
+			name:     "hashicorp style",
+			notes:    "UPGRADE NOTES:\n\n* note\n\nBUG FIXES:\n\n* fix",
+			expected: "!! UPGRADE NOTES:\n\n!! * note\n\nBUG FIXES:\n\n* fix",
+			found:    true,
+		},
+		{
+			name:     "markdown headings",
+			notes:    "### Breaking Changes\n* removed\n### Features\n* added",
+			expected: "!! ### Breaking Changes\n!! * removed\n### Features\n* added",
+			found:    true,
+		},
+		{
+			name:     "bold headings",
+			notes:    "**BREAKING CHANGES:**\n* removed",
+			expected: "!! **BREAKING CHANGES:**\n!! * removed",
+			found:    true,
+		},
+		{
+			name:     "nothing important",
+			notes:    "BUG FIXES:\n* mentions breaking: in a sentence",
+			expected: "BUG FIXES:\n* mentions breaking: in a sentence",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, found := highlightNotes(tt.notes, mark)
+			if result != tt.expected || found != tt.found {
+				t.Errorf("highlightNotes() = %q, %v, want %q, %v", result, found, tt.expected, tt.found)
+			}
+		})
+	}
+}
+
+func TestReleaseNotes(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/releases/terraform/1.6.0", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"version": "1.6.0", "url_changelog": "http://%s/CHANGELOG.md"}`, r.Host)
+	})
+	mux.HandleFunc("/CHANGELOG.md", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, testChangelog)
+	})
+	mux.HandleFunc("/api/v3/repos/opentofu/opentofu/releases/tags/v1.6.0", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"tag_name": "v1.6.0", "body": "### Breaking Changes\n* removed"}`)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	v := mustVersions(t, "1.6.0")[0]
+
+	tests := []struct {
+		name          string
+		tool          string
+		sources       []ReleaseSource
+		expected      string
+		errorContains string
+	}{
+		{
+			name:     "hashicorp changelog",
+			tool:     "terraform",
+			sources:  []ReleaseSource{&hashicorpSource{httpSource: httpSource{url: server.URL}, api: server.URL}},
+			expected: "UPGRADE NOTES:",
+		},
+		{
+			name:     "github release",
+			tool:     "tofu",
+			sources:  []ReleaseSource{newGitHubSource(server.URL, "opentofu/opentofu", nil)},
+			expected: "### Breaking Changes",
+		},
+		{
+			name:     "skips sources without release notes",
+			tool:     "terraform",
+			sources:  []ReleaseSource{&localSource{path: t.TempDir()}, newHashiCorpSource(server.URL, nil), &hashicorpSource{httpSource: httpSource{url: server.URL}, api: server.URL}},
+			expected: "UPGRADE NOTES:",
+		},
+		{
+			name:          "no source provides release notes",
+			tool:          "terraform",
+			sources:       []ReleaseSource{&localSource{path: t.TempDir()}},
+			errorContains: "no release source provides release notes",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tf := &Terraform{tool: knownTools[tt.tool], sources: tt.sources}
+			notes, err := tf.ReleaseNotes(v)
+			if tt.errorContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errorContains) {
+					t.Errorf("expected error containing %q, got %v", tt.errorContains, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !strings.HasPrefix(notes, tt.expected) {
+				t.Errorf("ReleaseNotes() = %q, want prefix %q", notes, tt.expected)
+			}
+		})
+	}
+}
+
+func TestPrintChangelog(t *testing.T) {
+	v := mustVersions(t, "1.6.0", "1.6.1")
+	notes := []releaseNotes{
+		{Version: v[0], Notes: "UPGRADE NOTES:\n* note"},
+		{Version: v[1], Err: fmt.Errorf("not found")},
+	}
+
+	var buf bytes.Buffer
+	printChangelog(&buf, notes, false)
+	for _, e := range []string{"## 1.6.0\n\n!! UPGRADE NOTES:\n!! * note", "## 1.6.1\n\nnot found", "!! Breaking changes or upgrade notes in: 1.6.0"} {
+		if !strings.Contains(buf.String(), e) {
+			t.Errorf("output does not contain %q:\n%s", e, buf.String())
+		}
+	}
+}
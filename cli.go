@@ -3,6 +3,8 @@ package main
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 
 	ver "github.com/hashicorp/go-version"
 	"github.com/spf13/cobra"
@@ -11,6 +13,24 @@ import (
 type App struct {
 	// entry point
 	Execute func() error
+
+	// list-versions
+	refresh bool
+	filter  versionFilter
+	output  string
+
+	// outdated
+	recursive bool
+	policy    outdatedPolicy
+
+	// changelog
+	latest bool
+
+	// which
+	install bool
+
+	// shim
+	shimDir string
 }
 
 func NewApp() *App {
@@ -25,8 +45,8 @@ func NewApp() *App {
 	}
 	a.Execute = func() error {
 		if err := rootCmd.Execute(); err != nil {
-			fmt.Fprintln(os.Stderr, err)
-			os.Exit(1)
+			logger.Error(err.Error())
+			os.Exit(exitCode(err))
 		}
 		return nil
 	}
@@ -42,20 +62,113 @@ func NewApp() *App {
 
 	// install
 	installCmd := &cobra.Command{
-		Use:   "install",
+		Use:   "install [tool@]version...",
 		Short: "install a version of terraform",
-		RunE:  a.installCmd,
+		Long: `Install one or more versions of a tool. Versions without a tool prefix
+(e.g. 1.6.2 rather than packer@1.10.0) are installed for the tool used in
+the current directory.`,
+		RunE: a.installCmd,
 	}
 	rootCmd.AddCommand(installCmd)
 
 	// list-versions
 	listVersionsCmd := &cobra.Command{
-		Use:   "list-versions",
+		Use:   "list-versions [tool]",
 		Short: "list versions of terraform",
+		Args:  cobra.MaximumNArgs(1),
 		RunE:  a.listVersionsCmd,
 	}
+	listVersionsCmd.Flags().BoolVar(&a.refresh, "refresh", false, "revalidate cached release listings")
+	listVersionsCmd.Flags().BoolVar(&a.filter.installedOnly, "installed", false, "only list installed versions")
+	listVersionsCmd.Flags().StringVar(&a.filter.constraint, "constraint", "", "only list versions matching the constraint, e.g. '~> 1.5'")
+	listVersionsCmd.Flags().BoolVar(&a.filter.prerelease, "prerelease", false, "include prerelease versions")
+	listVersionsCmd.Flags().StringVar(&a.filter.since, "since", "", "only list versions greater than or equal to this version")
+	listVersionsCmd.Flags().BoolVar(&a.filter.latestPerMinor, "latest-per-minor", false, "only list the latest version of every minor release")
+	listVersionsCmd.Flags().IntVar(&a.filter.limit, "limit", 0, "only list the newest n versions")
+	listVersionsCmd.Flags().StringVarP(&a.output, "output", "o", outputTable, "output format: table, json or yaml")
 	rootCmd.AddCommand(listVersionsCmd)
 
+	// outdated
+	outdatedCmd := &cobra.Command{
+		Use:   "outdated [dir...]",
+		Short: "report directories using outdated versions",
+		Long: `Compare the version selected in every directory (default: the current
+directory) with the newest version satisfying its constraint (wanted) and
+the newest version overall (latest), and report the gap as patch, minor or
+major.
+
+With --fail-on the command exits with 119 if any directory is behind by at
+least the given gap. --scope decides whether the gap to the wanted
+(constraint) or to the latest (any) version counts.`,
+		RunE: a.outdatedCmd,
+	}
+	outdatedCmd.Flags().BoolVarP(&a.recursive, "recursive", "r", false, "check all directories below the given ones that declare a version")
+	outdatedCmd.Flags().StringVar(&a.policy.FailOn, "fail-on", "", "exit non-zero on a gap of at least: none, patch, minor or major")
+	outdatedCmd.Flags().StringVar(&a.policy.Scope, "scope", "", "gap checked by --fail-on: constraint or any")
+	outdatedCmd.Flags().StringVarP(&a.output, "output", "o", outputTable, "output format: table, json or yaml")
+	rootCmd.AddCommand(outdatedCmd)
+
+	// changelog
+	changelogCmd := &cobra.Command{
+		Use:   "changelog [tool] <from>..<to> | [dir]",
+		Short: "show the release notes between two versions",
+		Long: `Show the release notes of every release after <from> up to and including
+<to>, e.g. 'wtf changelog 1.5.7..1.6.2'. Sections with breaking changes or
+upgrade notes are highlighted.
+
+Without a range, the release notes between the version selected in the
+directory (default: the current directory) and the newest version
+satisfying its constraint are shown.`,
+		Args: cobra.MaximumNArgs(2),
+		RunE: a.changelogCmd,
+	}
+	changelogCmd.Flags().BoolVar(&a.filter.prerelease, "prerelease", false, "include prerelease versions")
+	changelogCmd.Flags().BoolVar(&a.latest, "latest", false, "without a range, show the release notes up to the newest version even if it does not satisfy the constraint")
+	rootCmd.AddCommand(changelogCmd)
+
+	// which
+	whichCmd := &cobra.Command{
+		Use:   "which [dir]",
+		Short: "print the path of the binary used in a directory",
+		Long: `Print the absolute path of the binary which runs in the directory
+(default: the current directory), e.g. for editors and language servers
+which cannot use a shim.
+
+With --install, the newest release satisfying the constraint is installed
+if no installed version does.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: a.whichCmd,
+	}
+	whichCmd.Flags().BoolVar(&a.install, "install", false, "install the newest matching release if no installed version satisfies the constraint")
+	rootCmd.AddCommand(whichCmd)
+
+	// shim
+	shimCmd := &cobra.Command{
+		Use:   "shim",
+		Short: "manage the shims running wtf as a tool",
+		Long: `A shim is a symlink named like a tool (e.g. terraform) pointing at wtf,
+so running the tool runs the version selected by wtf. Without tool names,
+shims are managed for the global tool, the tools of projects and the tools
+of the tools section of the configuration.`,
+	}
+	shimCmd.PersistentFlags().StringVar(&a.shimDir, "dir", getBinDir(), "directory of the shims")
+	shimCmd.AddCommand(&cobra.Command{
+		Use:   "install [tool...]",
+		Short: "create shims and check that they are found first in PATH",
+		RunE:  a.shimInstallCmd,
+	})
+	shimCmd.AddCommand(&cobra.Command{
+		Use:   "remove [tool...]",
+		Short: "remove shims",
+		RunE:  a.shimRemoveCmd,
+	})
+	shimCmd.AddCommand(&cobra.Command{
+		Use:   "list [tool...]",
+		Short: "list shims and what running the tools resolves to",
+		RunE:  a.shimListCmd,
+	})
+	rootCmd.AddCommand(shimCmd)
+
 	// version
 	versionCmd := &cobra.Command{
 		Use:   "version",
@@ -68,28 +181,37 @@ func NewApp() *App {
 }
 
 func (a *App) execCmd(cmd *cobra.Command, args []string) {
-	runTerraform(args, true)
+	runTool("", args, true)
 }
 
 func (a *App) installCmd(cmd *cobra.Command, args []string) error {
 	k, err := NewConfiguration()
 	if err != nil {
-		return err
-	}
-
-	tf, err := NewTerraform(k.BinaryStorePath, true)
-	if err != nil {
-		return err
+		return withExitCode(exitConfig, err)
 	}
 
 	errs := []error{}
+	code := exitConfig
+
+	for _, arg := range args {
+		logger.Info("installing", "version", arg)
+		name, v := splitToolVersion(arg)
+		tool, err := a.tool(k, name)
+		if err != nil {
+			logger.Error(err.Error())
+			errs = append(errs, err)
+			continue
+		}
+
+		tf, err := NewTerraform(k.BinaryStorePath, tool, k.releaseCache(), true)
+		if err != nil {
+			return err
+		}
 
-	for _, v := range args {
-		fmt.Printf("Processing %s...\n", v)
 		this, err := ver.NewVersion(v)
 		if err != nil {
 			err = fmt.Errorf("version string '%s' could not be parsed: %s", v, err.Error())
-			fmt.Println(err.Error())
+			logger.Error(err.Error())
 			errs = append(errs, err)
 			continue
 		}
@@ -97,51 +219,444 @@ func (a *App) installCmd(cmd *cobra.Command, args []string) error {
 		filepath, err := tf.DownloadVersion(this)
 		if err != nil {
 			err = fmt.Errorf("version '%s' could not be downloaded: %s", v, err.Error())
-			fmt.Println(err.Error())
+			logger.Error(err.Error())
+			code = exitDownload
 			errs = append(errs, err)
 			continue
 		} else {
-			fmt.Printf("version '%s' installed at '%s'\n", v, filepath)
+			logger.Info("installed", "version", arg, "path", filepath)
 		}
 
 	}
 
 	if len(errs) > 0 {
-		return fmt.Errorf("%d error(s) occurred", len(errs))
+		return withExitCode(code, fmt.Errorf("%d error(s) occurred", len(errs)))
 	}
 	return nil
 }
 
 func (a *App) listVersionsCmd(cmd *cobra.Command, args []string) error {
 	k, err := NewConfiguration()
+	if err != nil {
+		return withExitCode(exitConfig, err)
+	}
+
+	name := ""
+	if len(args) > 0 {
+		name = args[0]
+	}
+	tool, err := a.tool(k, name)
+	if err != nil {
+		return withExitCode(exitResolution, err)
+	}
+
+	if a.refresh {
+		k.Cache.TTL = 0
+	}
+
+	tf, err := NewTerraform(k.BinaryStorePath, tool, k.releaseCache(), true)
 	if err != nil {
 		return err
 	}
 
-	tf, err := NewTerraform(k.BinaryStorePath, true)
+	wd, err := os.Getwd()
 	if err != nil {
 		return err
 	}
+	c, err := resolveConstraint(tool, wd)
+	if err != nil {
+		return withExitCode(exitResolution, err)
+	}
+	selected, _ := tf.FindLatest(c)
 
 	installed := tf.ListInstalled()
-	available, err := tf.ListAvailable()
+	releases := map[string]Release{}
+	versions := mergeVersions(installed, nil)
+	if !a.filter.installedOnly {
+		available, err := tf.ListReleases()
+		if err != nil {
+			return withExitCode(exitDownload, err)
+		}
+		for _, r := range available {
+			if r.Prerelease && !a.filter.prerelease {
+				continue
+			}
+			releases[r.Version.String()] = r
+		}
+		versions = mergeVersions(releaseVersions(available), installed)
+	}
+
+	versions, err = a.filter.apply(versions)
+	if err != nil {
+		return withExitCode(exitConfig, err)
+	}
+
+	infos := []versionInfo{}
+	for _, v := range versions {
+		r, ok := releases[v.String()]
+		if !ok {
+			if !a.filter.installedOnly && !containsVersion(installed, v) {
+				// flagged as prerelease by the release source
+				continue
+			}
+			r = newRelease(v)
+		}
+		info := versionInfo{
+			Version:    v.String(),
+			Installed:  containsVersion(installed, v),
+			Matches:    c.Check(v),
+			Selected:   selected != nil && selected.Equal(v),
+			Prerelease: r.Prerelease,
+			Withdrawn:  r.Withdrawn,
+		}
+		if !r.Date.IsZero() {
+			info.Date = r.Date.Format("2006-01-02")
+		}
+		infos = append(infos, info)
+	}
+	return printVersions(os.Stdout, a.output, infos)
+}
+
+func (a *App) outdatedCmd(cmd *cobra.Command, args []string) error {
+	k, err := NewConfiguration()
+	if err != nil {
+		return withExitCode(exitConfig, err)
+	}
+	policy := k.Outdated
+	if a.policy.FailOn != "" {
+		policy.FailOn = a.policy.FailOn
+	}
+	if a.policy.Scope != "" {
+		policy.Scope = a.policy.Scope
+	}
+	if err := policy.validate(); err != nil {
+		return withExitCode(exitConfig, err)
+	}
+
+	if len(args) == 0 {
+		args = []string{"."}
+	}
+	dirs := []string{}
+	for _, arg := range args {
+		dir, err := filepath.Abs(arg)
+		if err != nil {
+			return err
+		}
+		if !a.recursive {
+			dirs = append(dirs, dir)
+			continue
+		}
+		found, err := k.findProjectDirs(dir)
+		if err != nil {
+			return err
+		}
+		dirs = append(dirs, found...)
+	}
+
+	// releases are listed once per tool
+	tfs := map[string]*Terraform{}
+	releases := map[string][]Release{}
+
+	infos := []outdatedInfo{}
+	violations := 0
+	for _, dir := range dirs {
+		tool, err := k.toolFor(dir)
+		if err != nil {
+			return withExitCode(exitResolution, err)
+		}
+		c, err := resolveConstraint(tool, dir)
+		if err != nil {
+			return withExitCode(exitResolution, err)
+		}
+		tf, ok := tfs[tool.Name]
+		if !ok {
+			tf, err = NewTerraform(k.BinaryStorePath, tool, k.releaseCache(), false)
+			if err != nil {
+				return err
+			}
+			releases[tool.Name], err = tf.ListReleases()
+			if err != nil {
+				return withExitCode(exitDownload, err)
+			}
+			tfs[tool.Name] = tf
+		}
+
+		info := checkOutdated(relativeDir(dir), tool, c, tf.ListInstalled(), releases[tool.Name])
+		if policy.violated(info) {
+			violations++
+		}
+		infos = append(infos, info)
+	}
+
+	if err := printOutdated(os.Stdout, a.output, infos); err != nil {
+		return err
+	}
+	if violations > 0 {
+		return withExitCode(exitOutdated, fmt.Errorf("%d of %d directories are behind by at least a %s version (scope: %s)", violations, len(infos), policy.FailOn, policy.Scope))
+	}
+	return nil
+}
+
+func (a *App) changelogCmd(cmd *cobra.Command, args []string) error {
+	k, err := NewConfiguration()
+	if err != nil {
+		return withExitCode(exitConfig, err)
+	}
+
+	rangeArg, name, dir := "", "", "."
+	for _, arg := range args {
+		if isVersionRange(arg) {
+			rangeArg = arg
+		} else {
+			name = arg
+		}
+	}
+	if rangeArg == "" {
+		if len(args) > 1 {
+			return withExitCode(exitConfig, fmt.Errorf("expected a single directory, got %s", strings.Join(args, " ")))
+		}
+		if name != "" {
+			dir = name
+			name = ""
+		}
+	}
+	dir, err = filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+
+	var tool Tool
+	if name != "" {
+		tool, err = k.getTool(name)
+	} else {
+		tool, err = k.toolFor(dir)
+	}
+	if err != nil {
+		return withExitCode(exitResolution, err)
+	}
+
+	tf, err := NewTerraform(k.BinaryStorePath, tool, k.releaseCache(), false)
 	if err != nil {
 		return err
 	}
+	releases, err := tf.ListReleases()
+	if err != nil {
+		return withExitCode(exitDownload, err)
+	}
 
-	for _, v := range available {
-		isInstalled := false
-		for _, i := range installed {
-			if v.Equal(i) {
-				isInstalled = true
+	var from, to *ver.Version
+	if rangeArg != "" {
+		from, to, err = parseVersionRange(rangeArg)
+		if err != nil {
+			return withExitCode(exitConfig, err)
+		}
+	} else {
+		c, err := resolveConstraint(tool, dir)
+		if err != nil {
+			return withExitCode(exitResolution, err)
+		}
+		from, err = tf.FindLatest(c)
+		if err != nil {
+			return withExitCode(exitNotInstalled, fmt.Errorf("%s, use a range such as 1.5.7..1.6.2", err.Error()))
+		}
+		if !a.latest {
+			releases = matchingReleases(releases, c)
+		}
+		to = from
+		for _, r := range releases {
+			if (!r.Prerelease || a.filter.prerelease) && r.Version.GreaterThan(to) {
+				to = r.Version
 			}
 		}
-		out := v.String()
-		if isInstalled {
-			out = fmt.Sprintf("%s [installed]", out)
+	}
+
+	between := releasesBetween(releases, from, to, a.filter.prerelease)
+	if len(between) == 0 {
+		fmt.Printf("No %s releases after %s up to %s\n", tool.Name, from.String(), to.String())
+		return nil
+	}
+
+	notes := []releaseNotes{}
+	failed := 0
+	for _, r := range between {
+		n := releaseNotes{Version: r.Version, Date: r.Date}
+		n.Notes, n.Err = tf.ReleaseNotes(r.Version)
+		if n.Err != nil {
+			failed++
+		}
+		notes = append(notes, n)
+	}
+	if failed == len(notes) {
+		return withExitCode(exitDownload, notes[0].Err)
+	}
+
+	printChangelog(os.Stdout, notes, isTerminal(os.Stdout) && os.Getenv("NO_COLOR") == "")
+	return nil
+}
+
+// matchingReleases returns the releases satisfying c.
+func matchingReleases(releases []Release, c ver.Constraints) []Release {
+	out := []Release{}
+	for _, r := range releases {
+		if c.Check(r.Version) {
+			out = append(out, r)
 		}
-		fmt.Println(out)
 	}
+	return out
+}
+
+// relativeDir returns dir relative to the working directory if it is
+// located below it.
+func relativeDir(dir string) string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return dir
+	}
+	rel, err := filepath.Rel(wd, dir)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return dir
+	}
+	return rel
+}
+
+// tool returns the tool with the given name, or the tool used in the
+// working directory if name is empty.
+func (a *App) tool(k *conf, name string) (Tool, error) {
+	if name != "" {
+		return k.getTool(name)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return Tool{}, err
+	}
+	return k.toolFor(wd)
+}
+
+func (a *App) whichCmd(cmd *cobra.Command, args []string) error {
+	k, err := NewConfiguration()
+	if err != nil {
+		return withExitCode(exitConfig, err)
+	}
+
+	dir := "."
+	if len(args) > 0 {
+		dir = args[0]
+	}
+	dir, err = filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+
+	tool, err := k.toolFor(dir)
+	if err != nil {
+		return withExitCode(exitResolution, err)
+	}
+	c, err := resolveConstraint(tool, dir)
+	if err != nil {
+		return withExitCode(exitResolution, err)
+	}
+
+	tf, err := NewTerraform(k.BinaryStorePath, tool, k.releaseCache(), false)
+	if err != nil {
+		return err
+	}
+	path, err := tf.Which(c, a.install)
+	if err != nil {
+		return err
+	}
+	fmt.Println(path)
+	return nil
+}
+
+// shimTools returns the tool names given as arguments or the configured
+// ones, and the path of wtf the shims point at.
+func (a *App) shimTools(args []string) ([]string, string, error) {
+	k, err := NewConfiguration()
+	if err != nil {
+		return nil, "", withExitCode(exitConfig, err)
+	}
+	names := args
+	if len(names) == 0 {
+		names = k.shimNames()
+	}
+	for _, name := range names {
+		if _, err := k.getTool(name); err != nil {
+			return nil, "", withExitCode(exitConfig, err)
+		}
+	}
+	wtf, err := wtfExecutable()
+	return names, wtf, err
+}
+
+func (a *App) shimInstallCmd(cmd *cobra.Command, args []string) error {
+	names, wtf, err := a.shimTools(args)
+	if err != nil {
+		return err
+	}
+
+	errs := []error{}
+	statuses := []shimStatus{}
+	for _, name := range names {
+		filename, created, err := installShim(a.shimDir, name, wtf)
+		if err != nil {
+			logger.Error(err.Error())
+			errs = append(errs, err)
+			continue
+		}
+		if created {
+			logger.Info("created shim", "path", filename)
+		} else {
+			logger.Info("shim exists", "path", filename)
+		}
+		statuses = append(statuses, checkShim(a.shimDir, name, wtf, os.Getenv("PATH")))
+	}
+	reportShims(statuses, a.shimDir)
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%d error(s) occurred", len(errs))
+	}
+	return nil
+}
+
+func (a *App) shimRemoveCmd(cmd *cobra.Command, args []string) error {
+	names, wtf, err := a.shimTools(args)
+	if err != nil {
+		return err
+	}
+
+	errs := []error{}
+	for _, name := range names {
+		filename, removed, err := removeShim(a.shimDir, name, wtf)
+		if err != nil {
+			logger.Error(err.Error())
+			errs = append(errs, err)
+			continue
+		}
+		if removed {
+			logger.Info("removed shim", "path", filename)
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%d error(s) occurred", len(errs))
+	}
+	return nil
+}
+
+func (a *App) shimListCmd(cmd *cobra.Command, args []string) error {
+	names, wtf, err := a.shimTools(args)
+	if err != nil {
+		return err
+	}
+
+	statuses := []shimStatus{}
+	for _, name := range names {
+		statuses = append(statuses, checkShim(a.shimDir, name, wtf, os.Getenv("PATH")))
+	}
+	if err := printShims(os.Stdout, statuses); err != nil {
+		return err
+	}
+	reportShims(statuses, a.shimDir)
 	return nil
 }
 
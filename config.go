@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -26,9 +27,38 @@ func getDefaultDataDir() string {
 	return filepath.Join(dataHome, "wtf", "terraform-versions")
 }
 
+func getCacheDir() string {
+	cacheHome := os.Getenv("XDG_CACHE_HOME")
+	if cacheHome == "" {
+		home, _ := os.UserHomeDir()
+		cacheHome = filepath.Join(home, ".cache")
+	}
+	return filepath.Join(cacheHome, "wtf")
+}
+
+// getBinDir returns the directory shims are created in by default.
+func getBinDir() string {
+	binHome := os.Getenv("XDG_BIN_HOME")
+	if binHome == "" {
+		home, _ := os.UserHomeDir()
+		binHome = filepath.Join(home, ".local", "bin")
+	}
+	return binHome
+}
+
 type conf struct {
-	BinaryStorePath string  `yaml:"binary_store_path"`
-	Wrapper         wrapper `yaml:"wrapper"`
+	BinaryStorePath string          `yaml:"binary_store_path"`
+	Tool            string          `yaml:"tool"`
+	Tools           map[string]Tool `yaml:"tools"`
+	Sources         []sourceConfig  `yaml:"sources"`
+	Cache           cacheConf       `yaml:"cache"`
+	Projects        []project       `yaml:"projects"`
+	Outdated        outdatedPolicy  `yaml:"outdated"`
+	Wrapper         wrapper         `yaml:"wrapper"`
+	Wrappers        []wrapper       `yaml:"wrappers"`
+	Hooks           hooksConf       `yaml:"hooks"`
+	Signals         signalConf      `yaml:"signals"`
+	envConf         `yaml:",inline"`
 }
 
 func NewConfiguration() (*conf, error) {
@@ -37,17 +67,49 @@ func NewConfiguration() (*conf, error) {
 	configFile := getConfigFile()
 	data, err := os.ReadFile(configFile)
 	if os.IsNotExist(err) {
-		fmt.Printf("No config file '%s' found, using defaults\n", configFile)
+		logger.Debug("no config file found, using defaults", "file", configFile)
 	} else if err != nil {
 		return c, fmt.Errorf("config file '%s' could not be read: %s", configFile, err.Error())
 	}
-	err = yaml.Unmarshal(data, c)
+	if err := yaml.Unmarshal(data, c); err != nil {
+		return c, err
+	}
 
-	return c, err
+	dir := filepath.Dir(configFile)
+	if err := c.Wrapper.resolvePaths(dir); err != nil {
+		return c, err
+	}
+	for i := range c.Wrappers {
+		if err := c.Wrappers[i].resolvePaths(dir); err != nil {
+			return c, err
+		}
+	}
+	return c, nil
+}
+
+// cacheConf configures the cache of release listings. Listings younger than
+// TTL are used without contacting the release source.
+type cacheConf struct {
+	TTL time.Duration `yaml:"ttl"`
 }
 
 func NewConfigurationDefaults() *conf {
 	return &conf{
 		BinaryStorePath: getDefaultDataDir(),
+		Cache: cacheConf{
+			TTL: time.Hour,
+		},
+		Signals: signalConf{
+			GracePeriod: 30 * time.Second,
+		},
+		Outdated: outdatedPolicy{
+			FailOn: gapNone.String(),
+			Scope:  scopeConstraint,
+		},
 	}
 }
+
+// releaseCache returns the cache for release listings.
+func (c *conf) releaseCache() *httpCache {
+	return newHTTPCache(filepath.Join(getCacheDir(), "releases"), c.Cache.TTL)
+}
@@ -85,6 +85,41 @@ func TestGetDefaultDataDir(t *testing.T) {
 	}
 }
 
+func TestGetCacheDir(t *testing.T) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		t.Fatalf("could not get user home dir: %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		xdgCacheHome string
+		expected     string
+	}{
+		{
+			name:         "uses XDG_CACHE_HOME when set",
+			xdgCacheHome: "/custom/cache",
+			expected:     "/custom/cache/wtf",
+		},
+		{
+			name:         "falls back to ~/.cache when XDG_CACHE_HOME is empty",
+			xdgCacheHome: "",
+			expected:     filepath.Join(homeDir, ".cache", "wtf"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("XDG_CACHE_HOME", tt.xdgCacheHome)
+
+			result := getCacheDir()
+			if result != tt.expected {
+				t.Errorf("getCacheDir() = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
+
 func TestNewConfigurationDefaults(t *testing.T) {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
@@ -231,3 +266,39 @@ wrapper:
 		})
 	}
 }
+
+func TestNewConfigurationWrapperPaths(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", tmpDir)
+	t.Setenv("XDG_DATA_HOME", tmpDir)
+
+	configDir := filepath.Join(tmpDir, "wtf")
+	if err := os.MkdirAll(configDir, 0755); err != nil {
+		t.Fatalf("could not create config dir: %v", err)
+	}
+	content := `
+wrapper:
+  script_file: wrappers/default.sh
+wrappers:
+  - name: secrets
+    script_file: /etc/wtf/secrets.sh
+    partials: wrappers/partials
+`
+	if err := os.WriteFile(filepath.Join(configDir, "config.yaml"), []byte(content), 0644); err != nil {
+		t.Fatalf("could not write config file: %v", err)
+	}
+
+	config, err := NewConfiguration()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := filepath.Join(configDir, "wrappers", "default.sh"); config.Wrapper.ScriptFile != expected {
+		t.Errorf("Wrapper.ScriptFile = %q, want %q", config.Wrapper.ScriptFile, expected)
+	}
+	if config.Wrappers[0].ScriptFile != "/etc/wtf/secrets.sh" {
+		t.Errorf("absolute ScriptFile changed to %q", config.Wrappers[0].ScriptFile)
+	}
+	if expected := filepath.Join(configDir, "wrappers", "partials"); config.Wrappers[0].Partials != expected {
+		t.Errorf("Partials = %q, want %q", config.Wrappers[0].Partials, expected)
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	ver "github.com/hashicorp/go-version"
+)
+
+// dryRun describes how a tool would be run, as shown by --wtf-dry-run.
+type dryRun struct {
+	Tool             string
+	Version          string
+	Constraint       string
+	ConstraintSource string
+	Binary           string
+	WorkingDir       string
+	Wrapper          string
+	// Command is the shell-quoted command line; a wrapper script is shown
+	// as `<wrapper script>`.
+	Command string
+	// Env holds the variables added to or changed in the environment of
+	// wtf.
+	Env       []string
+	PreHooks  []string
+	PostHooks []string
+	Script    string
+}
+
+// newDryRun resolves everything needed to run the tool without starting
+// it, rendering the wrapper script in memory.
+func newDryRun(tf *Terraform, rc runContext, c ver.Constraints, w wrapper, hooks hooksConf, verbose bool) (dryRun, error) {
+	bin := tf.store.BinaryPath(tf.tool.Name, rc.Version)
+	d := dryRun{
+		Tool:             tf.tool.Name,
+		Version:          rc.Version.String(),
+		Constraint:       c.String(),
+		ConstraintSource: rc.ConstraintSource,
+		Binary:           bin,
+		WorkingDir:       rc.WorkingDir,
+		Wrapper:          w.Name,
+		Command:          shellquote(append([]string{bin}, rc.Args...)...),
+		Env:              changedEnv(os.Environ(), rc.Env),
+	}
+
+	if w.hasScript() {
+		script, err := w.render(bin, rc, verbose)
+		if err != nil {
+			return d, err
+		}
+		d.Script = string(script)
+		d.Command = strings.TrimSpace("<wrapper script> " + shellquote(w.scriptArgs(rc.Args)...))
+	}
+
+	var err error
+	if d.PreHooks, err = matchingHooks(hooks.Pre, rc); err != nil {
+		return d, err
+	}
+	if d.PostHooks, err = matchingHooks(hooks.Post, rc); err != nil {
+		return d, err
+	}
+	return d, nil
+}
+
+// matchingHooks returns the names of the hooks applying to rc.
+func matchingHooks(hooks []hook, rc runContext) ([]string, error) {
+	out := []string{}
+	for _, h := range hooks {
+		ok, err := h.Match.matches(rc)
+		if err != nil {
+			return out, fmt.Errorf("hook '%s': %w", h, err)
+		}
+		if ok {
+			out = append(out, h.String())
+		}
+	}
+	return out, nil
+}
+
+// changedEnv returns the entries of env which are not part of base. A nil
+// env is the environment of wtf, so nothing changed.
+func changedEnv(base, env []string) []string {
+	out := []string{}
+	if env == nil {
+		return out
+	}
+	known := map[string]bool{}
+	for _, e := range base {
+		known[e] = true
+	}
+	for _, e := range env {
+		if !known[e] {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// print writes the dry run to w, replacing secret values.
+func (d dryRun) print(w io.Writer, secrets *redactor) error {
+	constraint := dash(d.Constraint)
+	if d.ConstraintSource != "" {
+		constraint = fmt.Sprintf("%s (%s)", constraint, d.ConstraintSource)
+	}
+	wrapperName := d.Wrapper
+	if wrapperName == "" && d.Script != "" {
+		wrapperName = "default"
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Tool:\t%s\n", d.Tool)
+	fmt.Fprintf(tw, "Version:\t%s\n", d.Version)
+	fmt.Fprintf(tw, "Constraint:\t%s\n", constraint)
+	fmt.Fprintf(tw, "Binary:\t%s\n", d.Binary)
+	fmt.Fprintf(tw, "Working directory:\t%s\n", d.WorkingDir)
+	fmt.Fprintf(tw, "Wrapper:\t%s\n", dash(wrapperName))
+	fmt.Fprintf(tw, "Command:\t%s\n", secrets.redact(d.Command))
+	fmt.Fprintf(tw, "Pre hooks:\t%s\n", dash(strings.Join(d.PreHooks, ", ")))
+	fmt.Fprintf(tw, "Post hooks:\t%s\n", dash(strings.Join(d.PostHooks, ", ")))
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if len(d.Env) > 0 {
+		fmt.Fprintln(w, "Environment:")
+		for _, e := range d.Env {
+			fmt.Fprintf(w, "  %s\n", secrets.redact(e))
+		}
+	}
+	if d.Script != "" {
+		fmt.Fprintln(w, "Wrapper script:")
+		fmt.Fprint(w, secrets.redact(d.Script))
+		if !strings.HasSuffix(d.Script, "\n") {
+			fmt.Fprintln(w)
+		}
+	}
+	return nil
+}
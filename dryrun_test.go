@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDryRun(t *testing.T) {
+	store, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tf := &Terraform{tool: knownTools["terraform"], store: store}
+	v := mustVersions(t, "1.6.2")[0]
+	bin := store.BinaryPath("terraform", v)
+	rc := runContext{
+		Tool:             "terraform",
+		Version:          v,
+		Args:             []string{"plan", "-out=a plan"},
+		WorkingDir:       "/work/live/prod",
+		ConstraintSource: "/work/live/prod/versions.tf",
+		Env:              append(os.Environ(), "TF_VAR_password=hunter2", "TF_IN_AUTOMATION=1"),
+	}
+	hooks := hooksConf{
+		Pre:  []hook{{Name: "fmt", Command: "terraform fmt"}, {Name: "apply only", Command: "true", Match: matchRule{Subcommands: []string{"apply"}}}},
+		Post: []hook{{Webhook: "https://example.com/hook"}},
+	}
+	secrets := &redactor{}
+	secrets.add("hunter2")
+
+	tests := []struct {
+		name     string
+		wrapper  wrapper
+		expected []string
+	}{
+		{
+			name:    "without wrapper",
+			wrapper: wrapper{},
+			expected: []string{
+				"Tool:               terraform",
+				"Version:            1.6.2",
+				"Constraint:         ~> 1.6 (/work/live/prod/versions.tf)",
+				"Binary:             " + bin,
+				"Working directory:  /work/live/prod",
+				"Wrapper:            -",
+				"Command:            " + shellquote(bin, "plan", "-out=a plan"),
+				"Pre hooks:          fmt",
+				"Post hooks:         https://example.com/hook",
+				"Environment:",
+				"  TF_VAR_password=***",
+				"  TF_IN_AUTOMATION=1",
+			},
+		},
+		{
+			name:    "with wrapper",
+			wrapper: wrapper{Name: "secrets", ScriptTemplate: "#!/bin/sh\nPASSWORD=hunter2 {{.QuotedCommand}}", PassArgs: true},
+			expected: []string{
+				"Wrapper:            secrets",
+				"Command:            <wrapper script> plan '-out=a plan'",
+				"Wrapper script:",
+				"#!/bin/sh",
+				"PASSWORD=*** " + shellquote(bin) + " plan '-out=a plan'",
+			},
+		},
+		{
+			name:    "unnamed wrapper without args",
+			wrapper: wrapper{ScriptTemplate: "#!/bin/sh\n{{.Command}}\n"},
+			expected: []string{
+				"Wrapper:            default",
+				"Command:            <wrapper script>\n",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := newDryRun(tf, rc, mustConstraint(t, "~> 1.6"), tt.wrapper, hooks, false)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var out bytes.Buffer
+			if err := d.print(&out, secrets); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			for _, line := range tt.expected {
+				if !strings.Contains(out.String(), line) {
+					t.Errorf("output does not contain %q:\n%s", line, out.String())
+				}
+			}
+			if strings.Contains(out.String(), "hunter2") {
+				t.Errorf("output contains a secret:\n%s", out.String())
+			}
+		})
+	}
+
+	// a dry run never creates a script
+	runtimeHome := t.TempDir()
+	t.Setenv("XDG_RUNTIME_DIR", runtimeHome)
+	if _, err := newDryRun(tf, rc, mustConstraint(t, "~> 1.6"), wrapper{ScriptTemplate: "{{.Command}}"}, hooks, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(runtimeHome, "wtf")); !os.IsNotExist(err) {
+		t.Errorf("expected no runtime dir, got %v", err)
+	}
+}
+
+func TestChangedEnv(t *testing.T) {
+	base := []string{"HOME=/home/user", "PATH=/bin"}
+	tests := []struct {
+		name     string
+		env      []string
+		expected []string
+	}{
+		{name: "environment of wtf", env: nil, expected: []string{}},
+		{name: "unchanged", env: []string{"HOME=/home/user", "PATH=/bin"}, expected: []string{}},
+		{name: "added and changed", env: []string{"HOME=/home/user", "PATH=/usr/bin:/bin", "TF_LOG=debug"}, expected: []string{"PATH=/usr/bin:/bin", "TF_LOG=debug"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := changedEnv(base, tt.env)
+			if strings.Join(got, " ") != strings.Join(tt.expected, " ") {
+				t.Errorf("changedEnv() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
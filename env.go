@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// envVar is a variable declared in the configuration.
+type envVar struct {
+	Name  string
+	Value string
+}
+
+// envVars keeps the order of the configuration so values can refer to
+// variables declared before them.
+type envVars []envVar
+
+func (e *envVars) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: env must be a mapping of names to values", node.Line)
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		var value string
+		if err := node.Content[i+1].Decode(&value); err != nil {
+			return err
+		}
+		*e = append(*e, envVar{Name: node.Content[i].Value, Value: value})
+	}
+	return nil
+}
+
+// envConf declares environment variables for tools, globally or for a
+// project.
+type envConf struct {
+	Env      envVars    `yaml:"env"`
+	EnvFiles []string   `yaml:"env_files"`
+	Secrets  secretVars `yaml:"secrets"`
+}
+
+// environment collects the variables passed to a tool: the variables of
+// wtf itself overridden by the global env_files, env and secrets, followed
+// by those of every project matching dir in the order of the
+// configuration. Relative env files are looked up in dir; missing files
+// are skipped. The returned redactor knows the values of all secrets.
+func (c *conf) environment(dir string) ([]string, *redactor, error) {
+	env := newEnvironment(os.Environ())
+	secrets := &redactor{}
+
+	for _, ec := range c.envConfs(dir) {
+		for _, f := range ec.EnvFiles {
+			filename, err := expandPath(f)
+			if err != nil {
+				return nil, secrets, err
+			}
+			if !filepath.IsAbs(filename) {
+				filename = filepath.Join(dir, filename)
+			}
+			if err := env.loadDotenv(filename); err != nil {
+				return nil, secrets, err
+			}
+		}
+		for _, v := range ec.Env {
+			env.set(v.Name, expandEnv(v.Value, env.lookup))
+		}
+		for _, s := range ec.Secrets {
+			value, err := s.Source.resolve(dir, env.list())
+			if err != nil {
+				return nil, secrets, fmt.Errorf("secret %s: %w", s.Name, err)
+			}
+			secrets.add(value)
+			env.set(s.Name, value)
+		}
+	}
+	return env.list(), secrets, nil
+}
+
+// envConfs returns the global envConf followed by those of the projects
+// matching dir.
+func (c *conf) envConfs(dir string) []envConf {
+	confs := []envConf{c.envConf}
+	for _, p := range c.projectsFor(dir) {
+		confs = append(confs, p.envConf)
+	}
+	return confs
+}
+
+// secretNames returns the names and sources of the secrets declared for
+// dir.
+func (c *conf) secretNames(dir string) []string {
+	names := []string{}
+	for _, ec := range c.envConfs(dir) {
+		for _, s := range ec.Secrets {
+			names = append(names, fmt.Sprintf("%s (%s)", s.Name, s.Source))
+		}
+	}
+	return names
+}
+
+// environment is a set of environment variables which keeps their order.
+type environment struct {
+	names  []string
+	values map[string]string
+}
+
+func newEnvironment(vars []string) *environment {
+	e := &environment{values: map[string]string{}}
+	for _, v := range vars {
+		name, value, _ := strings.Cut(v, "=")
+		e.set(name, value)
+	}
+	return e
+}
+
+func (e *environment) set(name, value string) {
+	if _, ok := e.values[name]; !ok {
+		e.names = append(e.names, name)
+	}
+	e.values[name] = value
+}
+
+func (e *environment) lookup(name string) (string, bool) {
+	value, ok := e.values[name]
+	return value, ok
+}
+
+func (e *environment) list() []string {
+	out := make([]string, 0, len(e.names))
+	for _, name := range e.names {
+		out = append(out, name+"="+e.values[name])
+	}
+	return out
+}
+
+// expandEnv replaces $VAR and ${VAR} in s. ${VAR:-default} uses default if
+// VAR is unset or empty, $$ is a literal $.
+func expandEnv(s string, lookup func(string) (string, bool)) string {
+	return os.Expand(s, func(name string) string {
+		if name == "$" {
+			return "$"
+		}
+		name, def, hasDefault := strings.Cut(name, ":-")
+		value, _ := lookup(name)
+		if value == "" && hasDefault {
+			return expandEnv(def, lookup)
+		}
+		return value
+	})
+}
+
+func (e *environment) loadDotenv(filename string) error {
+	f, err := os.Open(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	vars, err := parseDotenv(f, e.lookup)
+	if err != nil {
+		return fmt.Errorf("%s: %w", filename, err)
+	}
+	for _, v := range vars {
+		e.set(v.Name, v.Value)
+	}
+	return nil
+}
+
+// parseDotenv parses a dotenv file: lines of NAME=value, optionally
+// prefixed with `export`. Values in single quotes are taken literally,
+// values in double quotes support \n, \t, \" and \\ escapes. Variables are
+// interpolated in unquoted and double quoted values, where variables from
+// earlier lines are visible. Lines starting with # are comments, as is the
+// rest of an unquoted value starting at ` #`.
+func parseDotenv(r io.Reader, lookup func(string) (string, bool)) (envVars, error) {
+	vars := envVars{}
+	local := func(name string) (string, bool) {
+		for i := len(vars) - 1; i >= 0; i-- {
+			if vars[i].Name == name {
+				return vars[i].Value, true
+			}
+		}
+		return lookup(name)
+	}
+
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		name, raw, ok := strings.Cut(line, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" || strings.ContainsAny(name, " \t") {
+			return vars, fmt.Errorf("line %d: expected NAME=value", n)
+		}
+		raw = strings.TrimSpace(raw)
+
+		var value string
+		switch {
+		case strings.HasPrefix(raw, "'"):
+			end := strings.Index(raw[1:], "'")
+			if end < 0 {
+				return vars, fmt.Errorf("line %d: unterminated single quote", n)
+			}
+			value = raw[1 : end+1]
+		case strings.HasPrefix(raw, `"`):
+			unquoted, err := unquoteDotenv(raw[1:])
+			if err != nil {
+				return vars, fmt.Errorf("line %d: %w", n, err)
+			}
+			value = expandEnv(unquoted, local)
+		default:
+			if i := strings.Index(raw, " #"); i >= 0 {
+				raw = strings.TrimSpace(raw[:i])
+			}
+			value = expandEnv(raw, local)
+		}
+		vars = append(vars, envVar{Name: name, Value: value})
+	}
+	return vars, scanner.Err()
+}
+
+// unquoteDotenv reads a double quoted value up to the closing quote.
+func unquoteDotenv(s string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '"':
+			return b.String(), nil
+		case '\\':
+			if i+1 == len(s) {
+				return "", fmt.Errorf("unterminated double quote")
+			}
+			i++
+			switch s[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			default:
+				b.WriteByte(s[i])
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", fmt.Errorf("unterminated double quote")
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestExpandEnv(t *testing.T) {
+	vars := map[string]string{"HOME": "/home/me", "EMPTY": ""}
+	lookup := func(name string) (string, bool) {
+		v, ok := vars[name]
+		return v, ok
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{input: "$HOME/.aws", expected: "/home/me/.aws"},
+		{input: "${HOME}/.aws", expected: "/home/me/.aws"},
+		{input: "${UNSET}", expected: ""},
+		{input: "${UNSET:-default}", expected: "default"},
+		{input: "${EMPTY:-default}", expected: "default"},
+		{input: "${HOME:-default}", expected: "/home/me"},
+		{input: "${UNSET:-$HOME}", expected: "/home/me"},
+		{input: "plain", expected: "plain"},
+		{input: "$$HOME", expected: "$HOME"},
+		{input: "cost: 5$$", expected: "cost: 5$"},
+		{input: "${UNSET:-$$5}", expected: "$5"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if s := expandEnv(tt.input, lookup); s != tt.expected {
+				t.Errorf("expandEnv() = %q, want %q", s, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseDotenv(t *testing.T) {
+	lookup := func(name string) (string, bool) {
+		if name == "USER" {
+			return "me", true
+		}
+		return "", false
+	}
+
+	tests := []struct {
+		name        string
+		content     string
+		expected    []string
+		expectError bool
+	}{
+		{
+			name:     "plain values",
+			content:  "# comment\n\nA=1\nexport B = two\nC=\n",
+			expected: []string{"A=1", "B=two", "C="},
+		},
+		{
+			name:     "interpolation",
+			content:  "PROFILE=${USER}-prod\nTF_VAR_profile=$PROFILE\nLITERAL='$USER'\n",
+			expected: []string{"PROFILE=me-prod", "TF_VAR_profile=me-prod", "LITERAL=$USER"},
+		},
+		{
+			name:     "double quotes",
+			content:  `A="a # b"` + "\n" + `B="line\nnext \"quoted\" $USER"` + "\n",
+			expected: []string{"A=a # b", "B=line\nnext \"quoted\" me"},
+		},
+		{
+			name:     "inline comments",
+			content:  "A=value # comment\nB=with#hash\n",
+			expected: []string{"A=value", "B=with#hash"},
+		},
+		{
+			name:        "missing equals sign",
+			content:     "A\n",
+			expectError: true,
+		},
+		{
+			name:        "unterminated quote",
+			content:     "A=\"value\n",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vars, err := parseDotenv(strings.NewReader(tt.content), lookup)
+			if tt.expectError {
+				if err == nil {
+					t.Error("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			result := []string{}
+			for _, v := range vars {
+				result = append(result, v.Name+"="+v.Value)
+			}
+			if strings.Join(result, "|") != strings.Join(tt.expected, "|") {
+				t.Errorf("parseDotenv() = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestEnvironment(t *testing.T) {
+	t.Setenv("WTF_TEST_BASE", "base")
+	t.Setenv("AWS_PROFILE", "from-shell")
+	root := t.TempDir()
+	dir := filepath.Join(root, "live", "prod")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("could not create dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ".env"), []byte("TF_VAR_region=eu-west-1\nFROM_FILE=${WTF_TEST_GLOBAL}\n"), 0644); err != nil {
+		t.Fatalf("could not write file: %v", err)
+	}
+
+	content := `
+env:
+  WTF_TEST_GLOBAL: ${WTF_TEST_BASE}-global
+  TF_IN_AUTOMATION: "1"
+env_files: [missing.env]
+projects:
+  - path: live/*
+    env_files: [.env]
+    env:
+      AWS_PROFILE: prod
+      TF_CLI_ARGS_plan: -var region=${TF_VAR_region} -lock-timeout=${LOCK_TIMEOUT:-5m}
+  - path: other
+    env:
+      AWS_PROFILE: other
+`
+	k := NewConfigurationDefaults()
+	if err := yaml.Unmarshal([]byte(content), k); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	env, _, err := k.environment(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	values := newEnvironment(env)
+	expected := map[string]string{
+		"WTF_TEST_BASE":    "base",
+		"WTF_TEST_GLOBAL":  "base-global",
+		"TF_IN_AUTOMATION": "1",
+		"TF_VAR_region":    "eu-west-1",
+		"FROM_FILE":        "base-global",
+		"AWS_PROFILE":      "prod",
+		"TF_CLI_ARGS_plan": "-var region=eu-west-1 -lock-timeout=5m",
+	}
+	for name, value := range expected {
+		if v, _ := values.lookup(name); v != value {
+			t.Errorf("%s = %q, want %q", name, v, value)
+		}
+	}
+
+	if _, _, err := k.environment(filepath.Join(root, "other")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	k.EnvFiles = []string{filepath.Join(dir, ".env"), filepath.Join(root, "broken.env")}
+	if err := os.WriteFile(filepath.Join(root, "broken.env"), []byte("broken\n"), 0644); err != nil {
+		t.Fatalf("could not write file: %v", err)
+	}
+	if _, _, err := k.environment(dir); err == nil || !strings.Contains(err.Error(), "broken.env") {
+		t.Errorf("expected error naming the broken file, got %v", err)
+	}
+}
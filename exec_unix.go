@@ -0,0 +1,12 @@
+//go:build !windows
+
+package main
+
+import "syscall"
+
+// canExec reports whether wtf can replace itself with the tool.
+const canExec = true
+
+func execProcess(path string, argv, env []string) error {
+	return syscall.Exec(path, argv, env)
+}
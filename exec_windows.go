@@ -0,0 +1,11 @@
+package main
+
+import "errors"
+
+// canExec reports whether wtf can replace itself with the tool. Windows
+// cannot replace a running process, so wtf always waits for the tool.
+const canExec = false
+
+func execProcess(path string, argv, env []string) error {
+	return errors.New("replacing the process is not supported on windows")
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// Exit codes of wtf itself, so they can be told apart from those of the
+// tool. They lie between the codes the tools use (0 to 2 for terraform) and
+// those a shell uses for commands which cannot be run (126, 127) or were
+// killed by a signal (128+n).
+const (
+	// exitOutdated: wtf outdated found a directory violating the policy
+	// set with --fail-on.
+	exitOutdated = 119
+	// exitFailure: any other failure of wtf.
+	exitFailure = 120
+	// exitConfig: the configuration, a wtf option or an argument is
+	// invalid, or the environment or a secret cannot be resolved.
+	exitConfig = 121
+	// exitResolution: the tool or its version constraint cannot be
+	// determined, e.g. because a version file is invalid.
+	exitResolution = 122
+	// exitNotInstalled: no installed version satisfies the constraint.
+	exitNotInstalled = 123
+	// exitDownload: a version could not be downloaded or installed.
+	exitDownload = 124
+	// exitHook: a pre hook failed, the tool was not run.
+	exitHook = 125
+	// exitCannotRun: the tool or wrapper script could not be started.
+	exitCannotRun = 126
+)
+
+// exitError is an error which ends wtf with a specific exit code.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error {
+	return e.err
+}
+
+// withExitCode attaches an exit code to err. A nil err stays nil.
+func withExitCode(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &exitError{code: code, err: err}
+}
+
+// exitCode returns the exit code attached to err, or exitFailure.
+func exitCode(err error) int {
+	var e *exitError
+	if errors.As(err, &e) {
+		return e.code
+	}
+	return exitFailure
+}
+
+// exitStatus returns the exit code of a finished process like a shell
+// does: 128+n if it was killed by signal n.
+func exitStatus(s *os.ProcessState) int {
+	if ws, ok := s.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		return 128 + int(ws.Signal())
+	}
+	return s.ExitCode()
+}
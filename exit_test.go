@@ -0,0 +1,60 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"testing"
+)
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected int
+	}{
+		{name: "plain error", err: errors.New("failed"), expected: exitFailure},
+		{name: "exit code", err: withExitCode(exitDownload, errors.New("failed")), expected: exitDownload},
+		{name: "wrapped exit code", err: fmt.Errorf("install: %w", withExitCode(exitConfig, errors.New("failed"))), expected: exitConfig},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exitCode(tt.err); got != tt.expected {
+				t.Errorf("exitCode() = %d, want %d", got, tt.expected)
+			}
+		})
+	}
+
+	if err := withExitCode(exitConfig, nil); err != nil {
+		t.Errorf("withExitCode(nil) = %v, want nil", err)
+	}
+}
+
+func TestExitStatus(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("signals require a POSIX system")
+	}
+
+	tests := []struct {
+		script   string
+		expected int
+	}{
+		{script: "exit 0", expected: 0},
+		{script: "exit 3", expected: 3},
+		{script: "kill -TERM $$", expected: 143},
+		{script: "kill -INT $$", expected: 130},
+		{script: "kill -KILL $$", expected: 137},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.script, func(t *testing.T) {
+			cmd := exec.Command("sh", "-c", tt.script)
+			_ = cmd.Run()
+			if got := exitStatus(cmd.ProcessState); got != tt.expected {
+				t.Errorf("exitStatus() = %d, want %d", got, tt.expected)
+			}
+		})
+	}
+}
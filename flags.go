@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// wtfFlagPrefix prefixes options of wtf which are given among the arguments
+// of a tool, e.g. `terraform plan --wtf-wrapper=none`. They are removed
+// before the tool is run.
+const wtfFlagPrefix = "--wtf-"
+
+// wtfFlags are the options of wtf given among the arguments of a tool.
+type wtfFlags struct {
+	// wrapper is the name of the wrapper to use instead of the first
+	// matching one.
+	wrapper string
+	// dryRun shows what would be run instead of running it.
+	dryRun bool
+}
+
+// parseWtfFlags extracts the options of wtf from args and returns the
+// remaining arguments. Options default to their environment variables
+// (WTF_DRY_RUN), so they can be set for shims which are not called
+// directly.
+func parseWtfFlags(args []string) (wtfFlags, []string, error) {
+	f := wtfFlags{}
+	if v := os.Getenv("WTF_DRY_RUN"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return f, args, fmt.Errorf("invalid value '%s' for WTF_DRY_RUN", v)
+		}
+		f.dryRun = b
+	}
+
+	rest := []string{}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, wtfFlagPrefix) {
+			rest = append(rest, arg)
+			continue
+		}
+
+		name, value, hasValue := strings.Cut(strings.TrimPrefix(arg, wtfFlagPrefix), "=")
+		switch name {
+		case "wrapper":
+			if !hasValue {
+				if i+1 >= len(args) {
+					return f, args, fmt.Errorf("%s requires a value", arg)
+				}
+				i++
+				value = args[i]
+			}
+			f.wrapper = value
+		case "dry-run":
+			f.dryRun = true
+			if hasValue {
+				b, err := strconv.ParseBool(value)
+				if err != nil {
+					return f, args, fmt.Errorf("invalid value '%s' for %s%s", value, wtfFlagPrefix, name)
+				}
+				f.dryRun = b
+			}
+		default:
+			return f, args, fmt.Errorf("unknown option %s", arg)
+		}
+	}
+	return f, rest, nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseWtfFlags(t *testing.T) {
+	tests := []struct {
+		name            string
+		args            []string
+		env             string
+		expectedWrapper string
+		expectedDryRun  bool
+		expectedArgs    []string
+		expectError     bool
+	}{
+		{
+			name:         "no options",
+			args:         []string{"plan", "-out=tfplan"},
+			expectedArgs: []string{"plan", "-out=tfplan"},
+		},
+		{
+			name:            "wrapper with equals sign",
+			args:            []string{"plan", "--wtf-wrapper=none", "-out=tfplan"},
+			expectedWrapper: "none",
+			expectedArgs:    []string{"plan", "-out=tfplan"},
+		},
+		{
+			name:            "wrapper with separate value",
+			args:            []string{"--wtf-wrapper", "secrets", "apply"},
+			expectedWrapper: "secrets",
+			expectedArgs:    []string{"apply"},
+		},
+		{
+			name:        "missing value",
+			args:        []string{"apply", "--wtf-wrapper"},
+			expectError: true,
+		},
+		{
+			name:           "dry run",
+			args:           []string{"apply", "--wtf-dry-run", "-auto-approve"},
+			expectedDryRun: true,
+			expectedArgs:   []string{"apply", "-auto-approve"},
+		},
+		{
+			name:           "dry run from environment",
+			args:           []string{"apply"},
+			env:            "1",
+			expectedDryRun: true,
+			expectedArgs:   []string{"apply"},
+		},
+		{
+			name:         "dry run disabled by option",
+			args:         []string{"apply", "--wtf-dry-run=false"},
+			env:          "true",
+			expectedArgs: []string{"apply"},
+		},
+		{
+			name:        "invalid dry run value",
+			args:        []string{"apply", "--wtf-dry-run=maybe"},
+			expectError: true,
+		},
+		{
+			name:        "invalid environment variable",
+			args:        []string{"apply"},
+			env:         "maybe",
+			expectError: true,
+		},
+		{
+			name:        "unknown option",
+			args:        []string{"apply", "--wtf-unknown"},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("WTF_DRY_RUN", tt.env)
+			flags, args, err := parseWtfFlags(tt.args)
+			if tt.expectError {
+				if err == nil {
+					t.Error("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if flags.wrapper != tt.expectedWrapper {
+				t.Errorf("wrapper = %q, want %q", flags.wrapper, tt.expectedWrapper)
+			}
+			if flags.dryRun != tt.expectedDryRun {
+				t.Errorf("dryRun = %v, want %v", flags.dryRun, tt.expectedDryRun)
+			}
+			if strings.Join(args, " ") != strings.Join(tt.expectedArgs, " ") {
+				t.Errorf("args = %q, want %q", args, tt.expectedArgs)
+			}
+		})
+	}
+}
@@ -1,59 +1,82 @@
 package main
 
 import (
-	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	ver "github.com/hashicorp/go-version"
 	"github.com/hashicorp/hcl/v2"
-	"github.com/hashicorp/hcl/v2/hclsimple"
+	"github.com/hashicorp/hcl/v2/gohcl"
+	"github.com/hashicorp/hcl/v2/hclparse"
 )
 
-type VersionFile struct {
-	Terraform TerraformConfig `hcl:"terraform,block"`
-}
-
-// TerraformConfig represents the terraform block in versions.tf.
-// The hclsimple decoder is strict and rejects unknown blocks/attributes.
-// We use the Remain field to capture any additional HCL content (such as
-// required_providers blocks) that we don't need to parse, allowing the
-// decoder to succeed without failing on unsupported block types.
-type TerraformConfig struct {
-	RequiredVersion string   `hcl:"required_version,optional"`
-	Remain          hcl.Body `hcl:",remain"`
-}
-
-func readConstraint() (ver.Constraints, error) {
-	wd, err := os.Getwd()
+// findRequiredVersion reads a constraint attribute (e.g. `required_version`)
+// of the given block (e.g. `terraform` or `packer`) from the files in dir
+// matching the glob pattern. If block is empty the attribute is read from
+// the top level of the files. The first file declaring the attribute wins.
+// Only the attribute itself is decoded, any other content of the files is
+// ignored. It also returns the file declaring the constraint, or an empty
+// string if none does.
+func findRequiredVersion(dir, pattern, block, attribute string) (ver.Constraints, string, error) {
+	filenames, err := filepath.Glob(filepath.Join(dir, pattern))
 	if err != nil {
-		return ver.Constraints{}, err
+		return ver.Constraints{}, "", err
 	}
+	sort.Strings(filenames)
 
-	filename := filepath.Join(wd, "versions.tf")
+	parser := hclparse.NewParser()
+	for _, filename := range filenames {
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			return ver.Constraints{}, "", err
+		}
 
-	if _, err := os.Stat(filename); errors.Is(err, os.ErrNotExist) {
-		return ver.Constraints{}, nil
-	} else if err != nil {
-		return ver.Constraints{}, err
-	}
+		f, diags := parser.ParseHCL(data, filename)
+		if diags.HasErrors() {
+			return ver.Constraints{}, "", fmt.Errorf("failed to parse %s: %w", filepath.Base(filename), diags)
+		}
 
-	data, err := os.ReadFile(filename)
-	if err != nil {
-		return ver.Constraints{}, err
-	}
+		bodies := []hcl.Body{f.Body}
+		if block != "" {
+			content, _, diags := f.Body.PartialContent(&hcl.BodySchema{
+				Blocks: []hcl.BlockHeaderSchema{{Type: block}},
+			})
+			if diags.HasErrors() {
+				return ver.Constraints{}, "", fmt.Errorf("failed to parse %s: %w", filepath.Base(filename), diags)
+			}
+			bodies = bodies[:0]
+			for _, b := range content.Blocks {
+				bodies = append(bodies, b.Body)
+			}
+		}
 
-	var versionFile VersionFile
-	if err := hclsimple.Decode("c.hcl", data, nil, &versionFile); err != nil {
-		return ver.Constraints{}, fmt.Errorf("failed to parse versions.tf: %w", err)
-	}
-	if strings.TrimSpace(versionFile.Terraform.RequiredVersion) != "" {
-		return ver.NewConstraint(versionFile.Terraform.RequiredVersion)
+		for _, body := range bodies {
+			attrs, _, diags := body.PartialContent(&hcl.BodySchema{
+				Attributes: []hcl.AttributeSchema{{Name: attribute}},
+			})
+			if diags.HasErrors() {
+				return ver.Constraints{}, "", fmt.Errorf("failed to parse %s: %w", filepath.Base(filename), diags)
+			}
+			attr, ok := attrs.Attributes[attribute]
+			if !ok {
+				continue
+			}
+
+			var requiredVersion string
+			if diags := gohcl.DecodeExpression(attr.Expr, nil, &requiredVersion); diags.HasErrors() {
+				return ver.Constraints{}, "", fmt.Errorf("failed to parse %s: %w", filepath.Base(filename), diags)
+			}
+			if strings.TrimSpace(requiredVersion) != "" {
+				c, err := ver.NewConstraint(requiredVersion)
+				return c, filename, err
+			}
+		}
 	}
 
-	return ver.Constraints{}, nil
+	return ver.Constraints{}, "", nil
 }
 
 func createDir(path string) error {
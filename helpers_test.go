@@ -87,7 +87,7 @@ func TestExpandPath(t *testing.T) {
 	}
 }
 
-func TestReadConstraint(t *testing.T) {
+func TestFindConstraintFromVersionsTF(t *testing.T) {
 	tests := []struct {
 		name              string
 		versionsContent   string
@@ -203,28 +203,16 @@ terraform {
 			}
 			defer os.RemoveAll(tmpDir)
 
-			// Save current working directory and restore after test
-			originalWd, err := os.Getwd()
-			if err != nil {
-				t.Fatalf("could not get working directory: %v", err)
-			}
-			defer func() { _ = os.Chdir(originalWd) }()
-
-			// Change to temp directory
-			if err := os.Chdir(tmpDir); err != nil {
-				t.Fatalf("could not change to temp dir: %v", err)
-			}
-
 			// Create versions.tf if needed
 			if tt.createFile {
-				err := os.WriteFile("versions.tf", []byte(tt.versionsContent), 0644)
+				err := os.WriteFile(filepath.Join(tmpDir, "versions.tf"), []byte(tt.versionsContent), 0644)
 				if err != nil {
 					t.Fatalf("could not write versions.tf: %v", err)
 				}
 			}
 
-			// Call readConstraint
-			constraint, err := readConstraint()
+			// Call findConstraint
+			constraint, _, err := findConstraint(knownTools["terraform"], tmpDir)
 
 			if tt.expectError {
 				if err == nil {
@@ -250,7 +238,7 @@ terraform {
 			}
 
 			if constraint.String() != tt.expectedConstraint {
-				t.Errorf("readConstraint() = %q, want %q", constraint.String(), tt.expectedConstraint)
+				t.Errorf("findConstraint() = %q, want %q", constraint.String(), tt.expectedConstraint)
 			}
 		})
 	}
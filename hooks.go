@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"text/template"
+	"time"
+)
+
+// hookTimeout limits how long a single hook may run.
+var hookTimeout = 30 * time.Second
+
+const (
+	hookPre  = "pre"
+	hookPost = "post"
+)
+
+// hooksConf lists the hooks run before and after a tool.
+type hooksConf struct {
+	Pre  []hook `yaml:"pre"`
+	Post []hook `yaml:"post"`
+}
+
+// hook runs a shell command or posts to a webhook before or after a tool
+// is run. A failing pre hook aborts the run unless IgnoreErrors is set;
+// failing post hooks only print a warning.
+type hook struct {
+	Name  string    `yaml:"name"`
+	Match matchRule `yaml:"match"`
+	// Command is run with `sh -c` (`cmd /C` on Windows). Details of the
+	// run are passed as WTF_* environment variables.
+	Command string `yaml:"command"`
+	// Webhook is a URL the hookEvent is posted to as JSON.
+	Webhook string `yaml:"webhook"`
+	// Payload is a template for the body posted to Webhook, rendered with
+	// the hookEvent.
+	Payload      string `yaml:"payload"`
+	IgnoreErrors bool   `yaml:"ignore_errors"`
+}
+
+func (h hook) String() string {
+	switch {
+	case h.Name != "":
+		return h.Name
+	case h.Command != "":
+		return h.Command
+	default:
+		return h.Webhook
+	}
+}
+
+// runResult describes a finished run of a tool.
+type runResult struct {
+	ExitCode int
+	Duration time.Duration
+}
+
+// hookEvent is passed to hooks.
+type hookEvent struct {
+	Phase      string   `json:"phase"`
+	Tool       string   `json:"tool"`
+	Version    string   `json:"version"`
+	Subcommand string   `json:"subcommand"`
+	Args       []string `json:"args"`
+	WorkingDir string   `json:"working_dir"`
+	// ExitCode and Duration are only set for post hooks.
+	ExitCode *int     `json:"exit_code,omitempty"`
+	Duration *float64 `json:"duration_seconds,omitempty"`
+}
+
+func newHookEvent(phase string, rc runContext, result *runResult) hookEvent {
+	e := hookEvent{
+		Phase:      phase,
+		Tool:       rc.Tool,
+		Subcommand: subcommand(rc.Args),
+		Args:       rc.Args,
+		WorkingDir: rc.WorkingDir,
+	}
+	if rc.Version != nil {
+		e.Version = rc.Version.String()
+	}
+	if result != nil {
+		seconds := result.Duration.Seconds()
+		e.ExitCode, e.Duration = &result.ExitCode, &seconds
+	}
+	return e
+}
+
+// env returns the event as environment variables for command hooks.
+func (e hookEvent) env() []string {
+	env := []string{
+		"WTF_HOOK=" + e.Phase,
+		"WTF_TOOL=" + e.Tool,
+		"WTF_VERSION=" + e.Version,
+		"WTF_SUBCOMMAND=" + e.Subcommand,
+		"WTF_ARGS=" + shellquote(e.Args...),
+		"WTF_WORKING_DIR=" + e.WorkingDir,
+	}
+	if e.ExitCode != nil {
+		env = append(env, "WTF_EXIT_CODE="+strconv.Itoa(*e.ExitCode))
+	}
+	if e.Duration != nil {
+		env = append(env, "WTF_DURATION="+strconv.FormatFloat(*e.Duration, 'f', 3, 64))
+	}
+	return env
+}
+
+// hasMatchingHooks reports whether any of hooks applies to rc. Hooks with
+// an invalid match rule count as matching, so runHooks reports them.
+func hasMatchingHooks(hooks []hook, rc runContext) bool {
+	for _, h := range hooks {
+		if ok, err := h.Match.matches(rc); ok || err != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// runHooks runs the matching hooks in order. For pre hooks, result is nil
+// and the first failing hook stops the run. Post hooks are all run.
+func runHooks(hooks []hook, rc runContext, result *runResult) error {
+	phase := hookPre
+	if result != nil {
+		phase = hookPost
+	}
+	e := newHookEvent(phase, rc, result)
+	env := rc.Env
+	if env == nil {
+		env = os.Environ()
+	}
+
+	errs := []error{}
+	for _, h := range hooks {
+		ok, err := h.Match.matches(rc)
+		if err != nil {
+			return fmt.Errorf("%s hook '%s': %w", phase, h, err)
+		}
+		if !ok {
+			continue
+		}
+
+		err = h.run(e, env)
+		if err == nil {
+			continue
+		}
+		err = fmt.Errorf("%s hook '%s' failed: %w", phase, h, err)
+		if h.IgnoreErrors {
+			logger.Warn(err.Error())
+			continue
+		}
+		if phase == hookPre {
+			return err
+		}
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// run runs the hook, commands get the environment of the tool env.
+func (h hook) run(e hookEvent, env []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), hookTimeout)
+	defer cancel()
+
+	switch {
+	case h.Command != "" && h.Webhook != "":
+		return fmt.Errorf("only one of command and webhook may be set")
+	case h.Command != "":
+		return h.runCommand(ctx, e, env)
+	case h.Webhook != "":
+		return h.post(ctx, e)
+	default:
+		return fmt.Errorf("neither command nor webhook set")
+	}
+}
+
+// runCommand runs the command of the hook. Its output goes to stderr so
+// the output of the tool stays intact.
+func (h hook) runCommand(ctx context.Context, e hookEvent, env []string) error {
+	shell, flag := "sh", "-c"
+	if runtime.GOOS == "windows" {
+		shell, flag = "cmd", "/C"
+	}
+	cmd := exec.CommandContext(ctx, shell, flag, h.Command)
+	cmd.Dir = e.WorkingDir
+	cmd.Env = append(env, e.env()...)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+func (h hook) post(ctx context.Context, e hookEvent) error {
+	var body bytes.Buffer
+	if h.Payload != "" {
+		tmpl, err := template.New("payload").Funcs(templateFuncs).Parse(h.Payload)
+		if err != nil {
+			return err
+		}
+		if err := tmpl.Execute(&body, e); err != nil {
+			return err
+		}
+	} else if err := json.NewEncoder(&body).Encode(e); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.Webhook, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s returned %s", h.Webhook, resp.Status)
+	}
+	return nil
+}
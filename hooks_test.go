@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunHooksWebhook(t *testing.T) {
+	bodies := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	rc := runContext{Tool: "terraform", Version: mustVersions(t, "1.6.2")[0], Args: []string{"apply", "-auto-approve"}, WorkingDir: "/work/live/prod"}
+	result := &runResult{ExitCode: 2, Duration: 1500 * time.Millisecond}
+
+	hooks := []hook{
+		{Name: "event", Webhook: server.URL + "/event"},
+		{Name: "slack", Webhook: server.URL + "/slack", Payload: `{"text": "{{.Tool}} {{.Subcommand}} exited with {{.ExitCode}}"}`},
+		{Name: "skipped", Webhook: server.URL + "/skipped", Match: matchRule{Subcommands: []string{"plan"}}},
+	}
+	if err := runHooks(hooks, rc, result); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(bodies) != 2 {
+		t.Fatalf("expected 2 requests, got %d: %q", len(bodies), bodies)
+	}
+
+	e := hookEvent{}
+	if err := json.Unmarshal([]byte(bodies[0]), &e); err != nil {
+		t.Fatalf("invalid event %q: %v", bodies[0], err)
+	}
+	if e.Phase != hookPost || e.Tool != "terraform" || e.Version != "1.6.2" || e.Subcommand != "apply" || *e.ExitCode != 2 || *e.Duration != 1.5 {
+		t.Errorf("unexpected event %+v", e)
+	}
+	if bodies[1] != `{"text": "terraform apply exited with 2"}` {
+		t.Errorf("unexpected payload %q", bodies[1])
+	}
+
+	// failing post hooks are reported, but do not stop other hooks
+	bodies = bodies[:0]
+	hooks = []hook{{Name: "fail", Webhook: server.URL + "/fail"}, {Name: "event", Webhook: server.URL + "/event"}}
+	err := runHooks(hooks, rc, result)
+	if err == nil || !strings.Contains(err.Error(), "post hook 'fail' failed") {
+		t.Errorf("expected error of failing hook, got %v", err)
+	}
+	if len(bodies) != 2 {
+		t.Errorf("expected both hooks to run, got %d requests", len(bodies))
+	}
+}
+
+func TestRunHooksCommand(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no POSIX shell available")
+	}
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out")
+	rc := runContext{Tool: "terraform", Version: mustVersions(t, "1.6.2")[0], Args: []string{"plan", "-out=a plan"}, WorkingDir: dir}
+
+	tests := []struct {
+		name          string
+		hooks         []hook
+		result        *runResult
+		expected      string
+		errorContains string
+	}{
+		{
+			name:     "pre hook environment",
+			hooks:    []hook{{Command: `echo "$WTF_HOOK $WTF_TOOL $WTF_VERSION $WTF_SUBCOMMAND $WTF_ARGS $(pwd)" > out`}},
+			expected: "pre terraform 1.6.2 plan plan '-out=a plan' " + dir + "\n",
+		},
+		{
+			name:     "post hook environment",
+			hooks:    []hook{{Command: `echo "$WTF_HOOK $WTF_EXIT_CODE $WTF_DURATION" > out`}},
+			result:   &runResult{ExitCode: 1, Duration: 2 * time.Second},
+			expected: "post 1 2.000\n",
+		},
+		{
+			name:          "failing pre hook aborts",
+			hooks:         []hook{{Name: "login", Command: "exit 3"}, {Command: "echo ran > out"}},
+			errorContains: "pre hook 'login' failed",
+		},
+		{
+			name:     "ignored errors",
+			hooks:    []hook{{Command: "exit 3", IgnoreErrors: true}, {Command: "echo ran > out"}},
+			expected: "ran\n",
+		},
+		{
+			name:     "non matching hooks are skipped",
+			hooks:    []hook{{Command: "exit 3", Match: matchRule{Subcommands: []string{"apply"}}}, {Command: "echo ran > out"}},
+			expected: "ran\n",
+		},
+		{
+			name:          "command or webhook required",
+			hooks:         []hook{{Name: "empty"}},
+			errorContains: "neither command nor webhook set",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Remove(out)
+			err := runHooks(tt.hooks, rc, tt.result)
+			if tt.errorContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errorContains) {
+					t.Errorf("expected error containing %q, got %v", tt.errorContains, err)
+				}
+				if _, err := os.Stat(out); err == nil {
+					t.Error("expected hooks after the failing one not to run")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			content, err := os.ReadFile(out)
+			if err != nil {
+				t.Fatalf("hook did not write output: %v", err)
+			}
+			if string(content) != tt.expected {
+				t.Errorf("hook wrote %q, want %q", content, tt.expected)
+			}
+		})
+	}
+}
+
+func TestHasMatchingHooks(t *testing.T) {
+	rc := runContext{Tool: "terraform", Version: mustVersions(t, "1.6.2")[0], Args: []string{"plan"}, WorkingDir: "/work"}
+
+	tests := []struct {
+		name     string
+		hooks    []hook
+		expected bool
+	}{
+		{name: "no hooks", expected: false},
+		{name: "unconditional hook", hooks: []hook{{Command: "true"}}, expected: true},
+		{name: "other subcommand", hooks: []hook{{Command: "true", Match: matchRule{Subcommands: []string{"apply"}}}}, expected: false},
+		{name: "invalid match rule", hooks: []hook{{Command: "true", Match: matchRule{Version: "not a constraint"}}}, expected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hasMatchingHooks(tt.hooks, rc); got != tt.expected {
+				t.Errorf("hasMatchingHooks() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
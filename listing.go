@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	ver "github.com/hashicorp/go-version"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+)
+
+// versionFilter selects the versions shown by list-versions.
+type versionFilter struct {
+	installedOnly  bool
+	constraint     string
+	prerelease     bool
+	since          string
+	latestPerMinor bool
+	limit          int
+}
+
+// versionInfo is a single line of the output of list-versions.
+type versionInfo struct {
+	Version   string `json:"version" yaml:"version"`
+	Date      string `json:"date,omitempty" yaml:"date,omitempty"`
+	Installed bool   `json:"installed" yaml:"installed"`
+	// Matches is set if the version satisfies the constraint of the
+	// working directory.
+	Matches bool `json:"matches_constraint" yaml:"matches_constraint"`
+	// Selected is set for the version `wtf exec` runs in the working
+	// directory.
+	Selected   bool `json:"selected" yaml:"selected"`
+	Prerelease bool `json:"prerelease" yaml:"prerelease"`
+	Withdrawn  bool `json:"withdrawn" yaml:"withdrawn"`
+}
+
+func (i versionInfo) notes() string {
+	notes := []string{}
+	if i.Selected {
+		notes = append(notes, "selected")
+	}
+	if i.Prerelease {
+		notes = append(notes, "prerelease")
+	}
+	if i.Withdrawn {
+		notes = append(notes, "withdrawn")
+	}
+	return strings.Join(notes, ", ")
+}
+
+// apply filters versions, which must be sorted in ascending order.
+func (f versionFilter) apply(versions ver.Collection) (ver.Collection, error) {
+	var c ver.Constraints
+	if f.constraint != "" {
+		var err error
+		c, err = ver.NewConstraint(f.constraint)
+		if err != nil {
+			return nil, fmt.Errorf("invalid constraint '%s': %w", f.constraint, err)
+		}
+	}
+	var since *ver.Version
+	if f.since != "" {
+		var err error
+		since, err = ver.NewVersion(f.since)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version '%s': %w", f.since, err)
+		}
+	}
+
+	out := ver.Collection{}
+	for _, v := range versions {
+		if v.Prerelease() != "" && !f.prerelease {
+			continue
+		}
+		if c != nil && !c.Check(v) {
+			continue
+		}
+		if since != nil && v.LessThan(since) {
+			continue
+		}
+		out = append(out, v)
+	}
+
+	if f.latestPerMinor {
+		latest := ver.Collection{}
+		for i, v := range out {
+			if i+1 < len(out) && sameMinor(v, out[i+1]) {
+				continue
+			}
+			latest = append(latest, v)
+		}
+		out = latest
+	}
+
+	if f.limit > 0 && len(out) > f.limit {
+		out = out[len(out)-f.limit:]
+	}
+	return out, nil
+}
+
+func sameMinor(a, b *ver.Version) bool {
+	sa, sb := a.Segments(), b.Segments()
+	return sa[0] == sb[0] && sa[1] == sb[1]
+}
+
+// mergeVersions returns the sorted union of a and b.
+func mergeVersions(a, b ver.Collection) ver.Collection {
+	seen := map[string]bool{}
+	out := ver.Collection{}
+	for _, v := range append(append(ver.Collection{}, a...), b...) {
+		if seen[v.String()] {
+			continue
+		}
+		seen[v.String()] = true
+		out = append(out, v)
+	}
+	sort.Sort(out)
+	return out
+}
+
+func containsVersion(versions ver.Collection, v *ver.Version) bool {
+	for _, i := range versions {
+		if v.Equal(i) {
+			return true
+		}
+	}
+	return false
+}
+
+func yesOrEmpty(b bool) string {
+	if b {
+		return "yes"
+	}
+	return ""
+}
+
+func printVersions(w io.Writer, format string, infos []versionInfo) error {
+	switch format {
+	case outputJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(infos)
+	case outputYAML:
+		enc := yaml.NewEncoder(w)
+		defer enc.Close()
+		return enc.Encode(infos)
+	case outputTable, "":
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "VERSION\tDATE\tINSTALLED\tMATCHES\tNOTES")
+		for _, i := range infos {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", i.Version, dash(i.Date), yesOrEmpty(i.Installed), yesOrEmpty(i.Matches), i.notes())
+		}
+		return tw.Flush()
+	default:
+		return fmt.Errorf("unknown output format '%s', use one of %s, %s, %s", format, outputTable, outputJSON, outputYAML)
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestVersionFilterApply(t *testing.T) {
+	versions := []string{"1.4.0", "1.4.7", "1.5.0-beta1", "1.5.0", "1.5.7", "1.6.0-alpha", "1.6.0", "1.6.2"}
+
+	tests := []struct {
+		name        string
+		filter      versionFilter
+		expected    string
+		expectError bool
+	}{
+		{
+			name:     "prereleases are excluded by default",
+			filter:   versionFilter{},
+			expected: "1.4.0\n1.4.7\n1.5.0\n1.5.7\n1.6.0\n1.6.2",
+		},
+		{
+			name:     "prereleases can be included",
+			filter:   versionFilter{prerelease: true},
+			expected: "1.4.0\n1.4.7\n1.5.0-beta1\n1.5.0\n1.5.7\n1.6.0-alpha\n1.6.0\n1.6.2",
+		},
+		{
+			name:     "constraint",
+			filter:   versionFilter{constraint: "~> 1.5.0"},
+			expected: "1.5.0\n1.5.7",
+		},
+		{
+			name:     "since",
+			filter:   versionFilter{since: "1.5.7"},
+			expected: "1.5.7\n1.6.0\n1.6.2",
+		},
+		{
+			name:     "latest per minor",
+			filter:   versionFilter{latestPerMinor: true},
+			expected: "1.4.7\n1.5.7\n1.6.2",
+		},
+		{
+			name:     "limit keeps the newest versions",
+			filter:   versionFilter{limit: 2},
+			expected: "1.6.0\n1.6.2",
+		},
+		{
+			name:     "combined",
+			filter:   versionFilter{since: "1.4.5", latestPerMinor: true, limit: 2},
+			expected: "1.5.7\n1.6.2",
+		},
+		{
+			name:        "invalid constraint",
+			filter:      versionFilter{constraint: "~>"},
+			expectError: true,
+		},
+		{
+			name:        "invalid since",
+			filter:      versionFilter{since: "latest"},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := tt.filter.apply(mustVersions(t, versions...))
+			if tt.expectError {
+				if err == nil {
+					t.Error("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if s := (&Terraform{versions: result}).String(); s != tt.expected {
+				t.Errorf("apply() = %q, want %q", s, tt.expected)
+			}
+		})
+	}
+}
+
+func TestMergeVersions(t *testing.T) {
+	result := mergeVersions(mustVersions(t, "1.6.0", "1.4.0"), mustVersions(t, "1.5.0", "1.6.0"))
+	if s := (&Terraform{versions: result}).String(); s != "1.4.0\n1.5.0\n1.6.0" {
+		t.Errorf("mergeVersions() = %q", s)
+	}
+}
+
+func TestPrintVersions(t *testing.T) {
+	infos := []versionInfo{
+		{Version: "1.5.7", Date: "2023-09-07", Installed: true, Matches: true, Selected: true},
+		{Version: "1.6.0-rc1", Prerelease: true, Withdrawn: true},
+	}
+
+	tests := []struct {
+		format      string
+		expected    []string
+		expectError bool
+	}{
+		{format: outputTable, expected: []string{
+			"VERSION    DATE        INSTALLED  MATCHES  NOTES",
+			"1.5.7      2023-09-07  yes        yes      selected",
+			"1.6.0-rc1  -                               prerelease, withdrawn",
+		}},
+		{format: outputJSON, expected: []string{`"version": "1.5.7"`, `"date": "2023-09-07"`, `"installed": true`, `"matches_constraint": true`, `"selected": true`, `"withdrawn": true`}},
+		{format: outputYAML, expected: []string{"- version: 1.5.7\n  date: \"2023-09-07\"\n  installed: true"}},
+		{format: "xml", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := printVersions(&buf, tt.format, infos)
+			if tt.expectError {
+				if err == nil {
+					t.Error("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			for _, e := range tt.expected {
+				if !strings.Contains(buf.String(), e) {
+					t.Errorf("output does not contain %q:\n%s", e, buf.String())
+				}
+			}
+		})
+	}
+}
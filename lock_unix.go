@@ -0,0 +1,27 @@
+//go:build !windows
+
+package main
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// lockFile takes an exclusive lock of f without waiting. It reports false
+// if another process holds the lock.
+func lockFile(f *os.File) (bool, error) {
+	err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// unlockFile removes the lock file filename and releases the lock of f.
+// The file is removed while it is locked, so no other process can lock it
+// in between.
+func unlockFile(f *os.File, filename string) {
+	os.Remove(filename)
+	f.Close()
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// lockFile takes an exclusive lock of f without waiting. It reports false
+// if another process holds the lock.
+func lockFile(f *os.File) (bool, error) {
+	err := windows.LockFileEx(windows.Handle(f.Fd()), windows.LOCKFILE_EXCLUSIVE_LOCK|windows.LOCKFILE_FAIL_IMMEDIATELY, 0, 1, 0, &windows.Overlapped{})
+	if errors.Is(err, windows.ERROR_LOCK_VIOLATION) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// unlockFile releases the lock of f and removes the lock file filename.
+// Windows cannot remove a file which is open, so it is closed first; if
+// another process opened it meanwhile, the file is left for that process.
+func unlockFile(f *os.File, filename string) {
+	f.Close()
+	os.Remove(filename)
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+)
+
+// logger writes the diagnostics of wtf. It always writes to stderr, so the
+// output of the tool on stdout can be piped into other programs. WTF_LOG
+// sets the level (quiet, info or debug), WTF_LOG_FORMAT=json switches to
+// JSON lines.
+var logger = newLogger(os.Stderr, os.Getenv("WTF_LOG"), os.Getenv("WTF_LOG_FORMAT"))
+
+// levelQuiet only lets errors pass.
+const levelQuiet = slog.LevelError
+
+func newLogger(w io.Writer, level, format string) *slog.Logger {
+	l, err := parseLogLevel(level)
+	var h slog.Handler = &textHandler{w: w, level: l, mu: &sync.Mutex{}}
+	if format == "json" {
+		h = slog.NewJSONHandler(w, &slog.HandlerOptions{Level: l})
+	}
+	log := slog.New(h)
+	if err != nil {
+		log.Warn(err.Error())
+	}
+	if format != "" && format != "json" && format != "text" {
+		log.Warn(fmt.Sprintf("unknown log format '%s', use one of text, json", format))
+	}
+	return log
+}
+
+func parseLogLevel(s string) (slog.Level, error) {
+	switch strings.ToLower(s) {
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "quiet":
+		return levelQuiet, nil
+	case "debug":
+		return slog.LevelDebug, nil
+	default:
+		return slog.LevelInfo, fmt.Errorf("unknown log level '%s', use one of quiet, info, debug", s)
+	}
+}
+
+// verboseLevel is the level of details `wtf exec` shows, but wtf running
+// as a shim only logs on request.
+func verboseLevel(verbose bool) slog.Level {
+	if verbose {
+		return slog.LevelInfo
+	}
+	return slog.LevelDebug
+}
+
+// textHandler writes one line per record for humans: the message prefixed
+// with the level unless it is info, followed by the attributes.
+type textHandler struct {
+	w     io.Writer
+	level slog.Level
+	attrs []slog.Attr
+	mu    *sync.Mutex
+}
+
+func (h *textHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level
+}
+
+func (h *textHandler) Handle(_ context.Context, r slog.Record) error {
+	var b strings.Builder
+	switch {
+	case r.Level >= slog.LevelError:
+		b.WriteString("error: ")
+	case r.Level >= slog.LevelWarn:
+		b.WriteString("warning: ")
+	case r.Level < slog.LevelInfo:
+		b.WriteString("debug: ")
+	}
+	b.WriteString(r.Message)
+
+	write := func(a slog.Attr) bool {
+		if !a.Equal(slog.Attr{}) {
+			fmt.Fprintf(&b, " %s=%s", a.Key, quoteLogValue(a.Value.String()))
+		}
+		return true
+	}
+	for _, a := range h.attrs {
+		write(a)
+	}
+	r.Attrs(write)
+	b.WriteString("\n")
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := io.WriteString(h.w, b.String())
+	return err
+}
+
+func (h *textHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	out := *h
+	out.attrs = append(append([]slog.Attr{}, h.attrs...), attrs...)
+	return &out
+}
+
+// WithGroup is not used by wtf, attributes of groups are written without
+// the group name.
+func (h *textHandler) WithGroup(name string) slog.Handler {
+	return h
+}
+
+// quoteLogValue quotes values containing spaces or quotes.
+func quoteLogValue(s string) string {
+	if s == "" || strings.ContainsAny(s, " \t\n\"=") {
+		return fmt.Sprintf("%q", s)
+	}
+	return s
+}
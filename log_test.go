@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestLogger(t *testing.T) {
+	tests := []struct {
+		name     string
+		level    string
+		format   string
+		expected string
+	}{
+		{
+			name:     "default",
+			expected: "installed version=1.6.2\nwarning: cache is stale url=\"https://example.com/a b\"\nerror: download failed\n",
+		},
+		{
+			name:     "quiet",
+			level:    "quiet",
+			expected: "error: download failed\n",
+		},
+		{
+			name:     "debug",
+			level:    "DEBUG",
+			expected: "debug: no config file\ninstalled version=1.6.2\nwarning: cache is stale url=\"https://example.com/a b\"\nerror: download failed\n",
+		},
+		{
+			name:     "unknown level",
+			level:    "loud",
+			expected: "warning: unknown log level 'loud', use one of quiet, info, debug\ninstalled version=1.6.2\nwarning: cache is stale url=\"https://example.com/a b\"\nerror: download failed\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			l := newLogger(&out, tt.level, tt.format)
+			l.Debug("no config file")
+			l.Info("installed", "version", "1.6.2")
+			l.Warn("cache is stale", "url", "https://example.com/a b")
+			l.Error("download failed")
+			if out.String() != tt.expected {
+				t.Errorf("logged %q, want %q", out.String(), tt.expected)
+			}
+		})
+	}
+}
+
+func TestLoggerJSON(t *testing.T) {
+	var out bytes.Buffer
+	l := newLogger(&out, "quiet", "json")
+	l.Info("installed")
+	l.Error("download failed", "version", "1.6.2")
+
+	record := map[string]any{}
+	if err := json.Unmarshal(out.Bytes(), &record); err != nil {
+		t.Fatalf("invalid JSON %q: %v", out.String(), err)
+	}
+	if record["level"] != "ERROR" || record["msg"] != "download failed" || record["version"] != "1.6.2" {
+		t.Errorf("unexpected record %v", record)
+	}
+}
+
+func TestVerboseLevel(t *testing.T) {
+	l := newLogger(&bytes.Buffer{}, "", "")
+	if !l.Enabled(context.Background(), verboseLevel(true)) {
+		t.Error("verbose messages should be logged at the default level")
+	}
+	if l.Enabled(context.Background(), verboseLevel(false)) {
+		t.Error("messages of shims should only be logged at debug level")
+	}
+}
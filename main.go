@@ -1,9 +1,11 @@
 package main
 
 import (
-	"fmt"
+	"context"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 )
 
 func main() {
@@ -13,51 +15,144 @@ func main() {
 		args = os.Args[1:]
 	}
 
-	if filepath.Base(bin) == "terraform" {
-		runTerraform(args, false)
+	// invoked via a symlink such as terraform -> wtf
+	name := strings.TrimSuffix(filepath.Base(bin), ".exe")
+	if isShim(name) {
+		runTool(name, args, false)
 	}
 
 	if err := NewApp().Execute(); err != nil {
-		fmt.Println(err)
-		os.Exit(-1)
+		logger.Error(err.Error())
+		os.Exit(exitCode(err))
 	}
 }
 
-func runTerraform(args []string, verbose bool) {
+// isShim reports whether wtf was invoked under the name of a built-in or
+// configured tool.
+func isShim(name string) bool {
+	if _, ok := knownTools[name]; ok {
+		return true
+	}
+	if name == "wtf" {
+		return false
+	}
+	k, err := NewConfiguration()
+	if err != nil {
+		return false
+	}
+	_, ok := k.Tools[name]
+	return ok
+}
+
+// runTool runs the tool with the given name. If name is empty, the tool is
+// chosen based on the configuration of the working directory.
+func runTool(name string, args []string, verbose bool) {
+	ctx := context.Background()
 	k, err := NewConfiguration()
 	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		logger.Error(err.Error())
+		os.Exit(exitConfig)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(exitFailure)
+	}
+
+	flags, args, err := parseWtfFlags(args)
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(exitConfig)
 	}
 
-	c, err := readConstraint()
+	var tool Tool
+	if name == "" {
+		tool, err = k.toolFor(wd)
+	} else {
+		tool, err = k.getTool(name)
+	}
 	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		logger.Error(err.Error())
+		os.Exit(exitResolution)
 	}
 
-	tf, err := NewTerraform(k.BinaryStorePath, verbose)
+	c, source, err := findConstraint(tool, wd)
 	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		logger.Error(err.Error())
+		os.Exit(exitResolution)
 	}
-	if verbose {
-		fmt.Printf("Version constraint: %s\n", c.String())
+
+	tf, err := NewTerraform(k.BinaryStorePath, tool, k.releaseCache(), verbose)
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(exitCode(err))
 	}
+	tf.gracePeriod = k.Signals.GracePeriod
+	level := verboseLevel(verbose)
+	logger.Log(ctx, level, "resolving version", "tool", tool.Name, "constraint", c.String(), "source", source)
 
 	latest, err := tf.FindLatest(c)
 	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		logger.Error(err.Error())
+		os.Exit(exitNotInstalled)
 	}
-	if verbose {
-		fmt.Printf("Version used: %s\n\n", latest.String())
+	logger.Log(ctx, level, "using version", "tool", tool.Name, "version", latest.String())
+
+	env, secrets, err := k.environment(wd)
+	if err != nil {
+		logger.Error(secrets.redact(err.Error()))
+		os.Exit(exitConfig)
 	}
 
-	s, err := tf.Run(latest, args, k.Wrapper)
+	rc := runContext{Tool: tool.Name, Version: latest, Args: args, WorkingDir: wd, ConstraintSource: source, Env: env}
+	w, err := k.selectWrapper(rc, flags.wrapper)
 	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		logger.Error(err.Error())
+		os.Exit(exitConfig)
+	}
+	if w.Name != "" {
+		logger.Log(ctx, level, "using wrapper", "wrapper", w.Name)
+	}
+	if names := k.secretNames(wd); len(names) > 0 {
+		logger.Log(ctx, level, "resolved secrets", "secrets", strings.Join(names, ", "))
+	}
+
+	if flags.dryRun {
+		d, err := newDryRun(tf, rc, c, w, k.Hooks, verbose)
+		if err == nil {
+			err = d.print(os.Stdout, secrets)
+		}
+		if err != nil {
+			logger.Error(secrets.redact(err.Error()))
+			os.Exit(exitConfig)
+		}
+		os.Exit(0)
+	}
+
+	if err := runHooks(k.Hooks.Pre, rc, nil); err != nil {
+		logger.Error(secrets.redact(err.Error()))
+		os.Exit(exitHook)
+	}
+
+	// without a wrapper script or post hooks there is nothing left to do
+	// once the tool exits
+	if canExec && !w.hasScript() && !hasMatchingHooks(k.Hooks.Post, rc) {
+		err := tf.Exec(rc)
+		logger.Error(secrets.redact(err.Error()))
+		os.Exit(exitCannotRun)
+	}
+
+	start := time.Now()
+	s, err := tf.Run(rc, w)
+	if err != nil {
+		logger.Error(secrets.redact(err.Error()))
+		os.Exit(exitCannotRun)
+	}
+
+	result := runResult{ExitCode: exitStatus(s), Duration: time.Since(start)}
+	if err := runHooks(k.Hooks.Post, rc, &result); err != nil {
+		logger.Warn(secrets.redact(err.Error()))
 	}
-	os.Exit(s.ExitCode())
+	os.Exit(result.ExitCode)
 }
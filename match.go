@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	ver "github.com/hashicorp/go-version"
+)
+
+// matchRule selects invocations of a tool. All conditions which are set
+// must be met; an empty rule matches every invocation.
+type matchRule struct {
+	// Path is a directory glob as used by projects.
+	Path string `yaml:"path"`
+	// Subcommands lists subcommands such as plan or apply.
+	Subcommands []string `yaml:"subcommands"`
+	// Version is a constraint the version being run must satisfy.
+	Version string `yaml:"version"`
+	// Env maps environment variables to glob patterns their value must
+	// match. Unlike in paths, `*` and `?` match `/` as well, so `*`
+	// requires the variable to be set.
+	Env map[string]string `yaml:"env"`
+}
+
+func (m matchRule) matches(rc runContext) (bool, error) {
+	if m.Path != "" && !matchPath(m.Path, rc.WorkingDir) {
+		return false, nil
+	}
+	if len(m.Subcommands) > 0 && !slices.Contains(m.Subcommands, subcommand(rc.Args)) {
+		return false, nil
+	}
+	if m.Version != "" {
+		c, err := ver.NewConstraint(m.Version)
+		if err != nil {
+			return false, fmt.Errorf("invalid version constraint '%s': %w", m.Version, err)
+		}
+		if rc.Version == nil || !c.Check(rc.Version) {
+			return false, nil
+		}
+	}
+	for name, pattern := range m.Env {
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return false, nil
+		}
+		matched, err := matchValue(pattern, value)
+		if err != nil {
+			return false, fmt.Errorf("invalid pattern '%s' for %s: %w", pattern, name, err)
+		}
+		if !matched {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// matchValue reports whether value matches the glob pattern. The syntax is
+// that of path.Match, but `*` and `?` match any character including `/`.
+func matchValue(pattern, value string) (bool, error) {
+	var expr strings.Builder
+	expr.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			expr.WriteString(".*")
+		case '?':
+			expr.WriteString(".")
+		case '\\':
+			i++
+			if i >= len(pattern) {
+				return false, fmt.Errorf("trailing backslash")
+			}
+			expr.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		case '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				return false, fmt.Errorf("unterminated character class")
+			}
+			class := pattern[i+1 : i+1+end]
+			if strings.HasPrefix(class, "^") || strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			expr.WriteString("[" + class + "]")
+			i += end + 1
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	expr.WriteString("$")
+	re, err := regexp.Compile(expr.String())
+	if err != nil {
+		return false, err
+	}
+	return re.MatchString(value), nil
+}
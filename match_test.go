@@ -0,0 +1,105 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestMatchRule(t *testing.T) {
+	t.Setenv("WTF_TEST_CI", "true")
+	t.Setenv("WTF_TEST_BRANCH", "feature/x")
+	v := mustVersions(t, "1.6.2")[0]
+	rc := runContext{Version: v, Args: []string{"-chdir=x", "apply"}, WorkingDir: "/work/repo/live/prod"}
+
+	tests := []struct {
+		name        string
+		rule        matchRule
+		expected    bool
+		expectError bool
+	}{
+		{name: "empty rule matches", rule: matchRule{}, expected: true},
+		{name: "path", rule: matchRule{Path: "live/*"}, expected: true},
+		{name: "other path", rule: matchRule{Path: "/work/other"}, expected: false},
+		{name: "subcommand", rule: matchRule{Subcommands: []string{"plan", "apply"}}, expected: true},
+		{name: "other subcommand", rule: matchRule{Subcommands: []string{"fmt", "validate"}}, expected: false},
+		{name: "version", rule: matchRule{Version: "~> 1.6.0"}, expected: true},
+		{name: "other version", rule: matchRule{Version: "< 1.6"}, expected: false},
+		{name: "invalid version", rule: matchRule{Version: "~>"}, expectError: true},
+		{name: "env value", rule: matchRule{Env: map[string]string{"WTF_TEST_CI": "true"}}, expected: true},
+		{name: "env set", rule: matchRule{Env: map[string]string{"WTF_TEST_CI": "*"}}, expected: true},
+		{name: "env set with slash", rule: matchRule{Env: map[string]string{"WTF_TEST_BRANCH": "*"}}, expected: true},
+		{name: "env pattern with slash", rule: matchRule{Env: map[string]string{"WTF_TEST_BRANCH": "feature/*"}}, expected: true},
+		{name: "env pattern across slash", rule: matchRule{Env: map[string]string{"WTF_TEST_BRANCH": "f*x"}}, expected: true},
+		{name: "env other value", rule: matchRule{Env: map[string]string{"WTF_TEST_CI": "false"}}, expected: false},
+		{name: "env unset", rule: matchRule{Env: map[string]string{"WTF_TEST_UNSET": "*"}}, expected: false},
+		{name: "invalid env pattern", rule: matchRule{Env: map[string]string{"WTF_TEST_CI": "["}}, expectError: true},
+		{
+			name:     "all conditions",
+			rule:     matchRule{Path: "live", Subcommands: []string{"apply"}, Version: ">= 1.5", Env: map[string]string{"WTF_TEST_CI": "t*"}},
+			expected: true,
+		},
+		{
+			name:     "one condition fails",
+			rule:     matchRule{Path: "live", Subcommands: []string{"plan"}, Version: ">= 1.5"},
+			expected: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, err := tt.rule.matches(rc)
+			if tt.expectError {
+				if err == nil {
+					t.Error("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if ok != tt.expected {
+				t.Errorf("matches() = %v, want %v", ok, tt.expected)
+			}
+		})
+	}
+}
+
+func TestMatchValue(t *testing.T) {
+	tests := []struct {
+		pattern     string
+		value       string
+		expected    bool
+		expectError bool
+	}{
+		{pattern: "*", value: "/home/user/.kube/config", expected: true},
+		{pattern: "*", value: "", expected: true},
+		{pattern: "release-?.?", value: "release-1.6", expected: true},
+		{pattern: "release-?", value: "release-1.6", expected: false},
+		{pattern: "main", value: "main", expected: true},
+		{pattern: "main", value: "mainline", expected: false},
+		{pattern: "v[0-9].*", value: "v1.6", expected: true},
+		{pattern: "[!v]*", value: "v1.6", expected: false},
+		{pattern: "a.b", value: "axb", expected: false},
+		{pattern: `\*`, value: "*", expected: true},
+		{pattern: `\*`, value: "x", expected: false},
+		{pattern: "[", expectError: true},
+		{pattern: `x\`, expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.value, func(t *testing.T) {
+			matched, err := matchValue(tt.pattern, tt.value)
+			if tt.expectError {
+				if err == nil {
+					t.Error("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if matched != tt.expected {
+				t.Errorf("matchValue(%q, %q) = %v, want %v", tt.pattern, tt.value, matched, tt.expected)
+			}
+		})
+	}
+}
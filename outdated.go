@@ -0,0 +1,238 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	ver "github.com/hashicorp/go-version"
+	"gopkg.in/yaml.v3"
+)
+
+// gap is the distance between two versions.
+type gap int
+
+const (
+	gapNone gap = iota
+	gapPatch
+	gapMinor
+	gapMajor
+	// gapMissing means no installed version satisfies the constraint.
+	gapMissing
+)
+
+var gapNames = map[gap]string{
+	gapNone:    "none",
+	gapPatch:   "patch",
+	gapMinor:   "minor",
+	gapMajor:   "major",
+	gapMissing: "missing",
+}
+
+func (g gap) String() string {
+	return gapNames[g]
+}
+
+func (g gap) MarshalText() ([]byte, error) {
+	return []byte(g.String()), nil
+}
+
+// parseGap parses the level of an outdated policy.
+func parseGap(s string) (gap, error) {
+	for g, name := range gapNames {
+		if s == name && g != gapMissing {
+			return g, nil
+		}
+	}
+	return gapNone, fmt.Errorf("unknown level '%s', use one of none, patch, minor, major", s)
+}
+
+// versionGap returns how far from is behind to.
+func versionGap(from, to *ver.Version) gap {
+	if from == nil {
+		return gapMissing
+	}
+	if to == nil || !from.LessThan(to) {
+		return gapNone
+	}
+	f, t := from.Segments(), to.Segments()
+	switch {
+	case f[0] != t[0]:
+		return gapMajor
+	case f[1] != t[1]:
+		return gapMinor
+	default:
+		return gapPatch
+	}
+}
+
+const (
+	scopeConstraint = "constraint"
+	scopeAny        = "any"
+)
+
+// outdatedPolicy decides when `wtf outdated` exits non-zero. FailOn is the
+// smallest gap that fails, Scope chooses whether the gap to the newest
+// version satisfying the constraint or to the newest version overall counts.
+type outdatedPolicy struct {
+	FailOn string `yaml:"fail_on"`
+	Scope  string `yaml:"scope"`
+}
+
+func (p outdatedPolicy) validate() error {
+	if _, err := parseGap(p.FailOn); err != nil {
+		return err
+	}
+	if p.Scope != scopeConstraint && p.Scope != scopeAny {
+		return fmt.Errorf("unknown scope '%s', use one of %s, %s", p.Scope, scopeConstraint, scopeAny)
+	}
+	return nil
+}
+
+// violated reports whether i fails the policy.
+func (p outdatedPolicy) violated(i outdatedInfo) bool {
+	level, err := parseGap(p.FailOn)
+	if err != nil || level == gapNone {
+		return false
+	}
+	g := i.WantedGap
+	if p.Scope == scopeAny {
+		g = i.LatestGap
+	}
+	return g >= level
+}
+
+// outdatedInfo compares the version selected in a directory with the
+// newest version satisfying its constraint (wanted) and the newest version
+// overall (latest).
+type outdatedInfo struct {
+	Dir        string `json:"dir" yaml:"dir"`
+	Tool       string `json:"tool" yaml:"tool"`
+	Constraint string `json:"constraint" yaml:"constraint"`
+	Current    string `json:"current" yaml:"current"`
+	Wanted     string `json:"wanted" yaml:"wanted"`
+	Latest     string `json:"latest" yaml:"latest"`
+	WantedGap  gap    `json:"wanted_gap" yaml:"wanted_gap"`
+	LatestGap  gap    `json:"latest_gap" yaml:"latest_gap"`
+}
+
+// checkOutdated compares the newest installed version satisfying c with
+// the releases. Prereleases and withdrawn releases are ignored.
+func checkOutdated(dir string, t Tool, c ver.Constraints, installed ver.Collection, releases []Release) outdatedInfo {
+	var current, wanted, latest *ver.Version
+	for _, v := range installed {
+		if c.Check(v) && (current == nil || v.GreaterThan(current)) {
+			current = v
+		}
+	}
+	for _, r := range releases {
+		if r.Prerelease || r.Withdrawn || r.Version.Prerelease() != "" {
+			continue
+		}
+		if latest == nil || r.Version.GreaterThan(latest) {
+			latest = r.Version
+		}
+		if c.Check(r.Version) && (wanted == nil || r.Version.GreaterThan(wanted)) {
+			wanted = r.Version
+		}
+	}
+
+	return outdatedInfo{
+		Dir:        dir,
+		Tool:       t.Name,
+		Constraint: c.String(),
+		Current:    versionString(current),
+		Wanted:     versionString(wanted),
+		Latest:     versionString(latest),
+		WantedGap:  versionGap(current, wanted),
+		LatestGap:  versionGap(current, latest),
+	}
+}
+
+func versionString(v *ver.Version) string {
+	if v == nil {
+		return ""
+	}
+	return v.String()
+}
+
+// hasConstraintFiles reports whether dir itself contains the version file
+// or configuration files of t.
+func hasConstraintFiles(t Tool, dir string) bool {
+	if t.VersionFile != "" {
+		if info, err := os.Stat(filepath.Join(dir, t.VersionFile)); err == nil && !info.IsDir() {
+			return true
+		}
+	}
+	if t.ConfigFiles != "" {
+		if matches, _ := filepath.Glob(filepath.Join(dir, t.ConfigFiles)); len(matches) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// findProjectDirs walks root and returns all directories declaring a
+// version for the tool used in them. Hidden directories such as .terraform
+// or .terragrunt-cache are skipped.
+func (c *conf) findProjectDirs(root string) ([]string, error) {
+	dirs := []string{}
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if path != root && strings.HasPrefix(d.Name(), ".") {
+			return filepath.SkipDir
+		}
+		t, err := c.toolFor(path)
+		if err != nil {
+			return err
+		}
+		if hasConstraintFiles(t, path) {
+			dirs = append(dirs, path)
+		}
+		return nil
+	})
+	return dirs, err
+}
+
+func printOutdated(w io.Writer, format string, infos []outdatedInfo) error {
+	switch format {
+	case outputJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(infos)
+	case outputYAML:
+		enc := yaml.NewEncoder(w)
+		defer enc.Close()
+		return enc.Encode(infos)
+	case outputTable, "":
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "DIRECTORY\tTOOL\tCONSTRAINT\tCURRENT\tWANTED\tLATEST\tGAP")
+		for _, i := range infos {
+			g := i.WantedGap.String()
+			if i.LatestGap > i.WantedGap && i.LatestGap != gapMissing {
+				g = fmt.Sprintf("%s (%s outside constraint)", g, i.LatestGap)
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", i.Dir, i.Tool, dash(i.Constraint), dash(i.Current), dash(i.Wanted), dash(i.Latest), g)
+		}
+		return tw.Flush()
+	default:
+		return fmt.Errorf("unknown output format '%s', use one of %s, %s, %s", format, outputTable, outputJSON, outputYAML)
+	}
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
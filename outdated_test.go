@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	ver "github.com/hashicorp/go-version"
+)
+
+func TestVersionGap(t *testing.T) {
+	tests := []struct {
+		from     string
+		to       string
+		expected gap
+	}{
+		{from: "1.5.7", to: "1.5.7", expected: gapNone},
+		{from: "1.5.7", to: "1.5.2", expected: gapNone},
+		{from: "1.5.2", to: "1.5.7", expected: gapPatch},
+		{from: "1.5.7", to: "1.6.0", expected: gapMinor},
+		{from: "0.15.5", to: "1.0.0", expected: gapMajor},
+		{from: "", to: "1.0.0", expected: gapMissing},
+		{from: "1.0.0", to: "", expected: gapNone},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.from+"->"+tt.to, func(t *testing.T) {
+			var from, to *ver.Version
+			if tt.from != "" {
+				from = mustVersions(t, tt.from)[0]
+			}
+			if tt.to != "" {
+				to = mustVersions(t, tt.to)[0]
+			}
+			if g := versionGap(from, to); g != tt.expected {
+				t.Errorf("versionGap() = %s, want %s", g, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCheckOutdated(t *testing.T) {
+	tool := knownTools["terraform"]
+	releases := []Release{}
+	for _, v := range mustVersions(t, "1.4.0", "1.5.0", "1.5.5", "1.5.7", "1.6.0", "1.7.0-rc1") {
+		releases = append(releases, newRelease(v))
+	}
+	// 1.6.1 is withdrawn, 1.6.2 flagged as prerelease by the source
+	withdrawn := newRelease(mustVersions(t, "1.6.1")[0])
+	withdrawn.Withdrawn = true
+	prerelease := newRelease(mustVersions(t, "1.6.2")[0])
+	prerelease.Prerelease = true
+	releases = append(releases, withdrawn, prerelease)
+
+	tests := []struct {
+		name       string
+		constraint string
+		installed  []string
+		expected   outdatedInfo
+	}{
+		{
+			name:       "patch behind within constraint",
+			constraint: "~> 1.5.0",
+			installed:  []string{"1.5.5", "1.6.0"},
+			expected:   outdatedInfo{Current: "1.5.5", Wanted: "1.5.7", Latest: "1.6.0", WantedGap: gapPatch, LatestGap: gapMinor},
+		},
+		{
+			name:       "up to date",
+			constraint: ">= 1.5",
+			installed:  []string{"1.6.0"},
+			expected:   outdatedInfo{Current: "1.6.0", Wanted: "1.6.0", Latest: "1.6.0", WantedGap: gapNone, LatestGap: gapNone},
+		},
+		{
+			name:      "no constraint",
+			installed: []string{"1.4.0"},
+			expected:  outdatedInfo{Current: "1.4.0", Wanted: "1.6.0", Latest: "1.6.0", WantedGap: gapMinor, LatestGap: gapMinor},
+		},
+		{
+			name:       "nothing installed",
+			constraint: "~> 1.5.0",
+			installed:  []string{"1.6.0"},
+			expected:   outdatedInfo{Wanted: "1.5.7", Latest: "1.6.0", WantedGap: gapMissing, LatestGap: gapMissing},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := ver.Constraints{}
+			if tt.constraint != "" {
+				c = mustConstraint(t, tt.constraint)
+			}
+			info := checkOutdated("stack", tool, c, mustVersions(t, tt.installed...), releases)
+			tt.expected.Dir = "stack"
+			tt.expected.Tool = "terraform"
+			tt.expected.Constraint = c.String()
+			if info != tt.expected {
+				t.Errorf("checkOutdated() = %+v, want %+v", info, tt.expected)
+			}
+		})
+	}
+}
+
+func TestOutdatedPolicy(t *testing.T) {
+	info := outdatedInfo{WantedGap: gapPatch, LatestGap: gapMajor}
+
+	tests := []struct {
+		policy      outdatedPolicy
+		violated    bool
+		expectError bool
+	}{
+		{policy: outdatedPolicy{FailOn: "none", Scope: scopeConstraint}},
+		{policy: outdatedPolicy{FailOn: "patch", Scope: scopeConstraint}, violated: true},
+		{policy: outdatedPolicy{FailOn: "minor", Scope: scopeConstraint}},
+		{policy: outdatedPolicy{FailOn: "minor", Scope: scopeAny}, violated: true},
+		{policy: outdatedPolicy{FailOn: "major", Scope: scopeAny}, violated: true},
+		{policy: outdatedPolicy{FailOn: "huge", Scope: scopeAny}, expectError: true},
+		{policy: outdatedPolicy{FailOn: "patch", Scope: "all"}, expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy.FailOn+"/"+tt.policy.Scope, func(t *testing.T) {
+			err := tt.policy.validate()
+			if tt.expectError {
+				if err == nil {
+					t.Error("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if v := tt.policy.violated(info); v != tt.violated {
+				t.Errorf("violated() = %v, want %v", v, tt.violated)
+			}
+		})
+	}
+
+	missing := outdatedInfo{WantedGap: gapMissing, LatestGap: gapMissing}
+	if !(outdatedPolicy{FailOn: "major", Scope: scopeConstraint}).violated(missing) {
+		t.Error("expected a directory without an installed version to violate the policy")
+	}
+}
+
+func TestFindProjectDirs(t *testing.T) {
+	root := t.TempDir()
+	files := []string{
+		"live/prod/versions.tf",
+		"live/prod/.terraform/modules/vpc/versions.tf",
+		"live/stage/.opentofu-version",
+		"modules/vpc/main.tf",
+		"docs/README.md",
+	}
+	for _, f := range files {
+		filename := filepath.Join(root, f)
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatalf("could not create dir: %v", err)
+		}
+		if err := os.WriteFile(filename, nil, 0644); err != nil {
+			t.Fatalf("could not write file: %v", err)
+		}
+	}
+
+	k := NewConfigurationDefaults()
+	dirs, err := k.findProjectDirs(root)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	rel := []string{}
+	for _, d := range dirs {
+		r, _ := filepath.Rel(root, d)
+		rel = append(rel, filepath.ToSlash(r))
+	}
+	expected := "live/prod live/stage"
+	if s := strings.Join(rel, " "); s != expected {
+		t.Errorf("findProjectDirs() = %q, want %q", s, expected)
+	}
+}
+
+func TestPrintOutdated(t *testing.T) {
+	infos := []outdatedInfo{
+		{Dir: "live/prod", Tool: "terraform", Constraint: "~> 1.5.0", Current: "1.5.5", Wanted: "1.5.7", Latest: "1.6.0", WantedGap: gapPatch, LatestGap: gapMinor},
+	}
+
+	var buf bytes.Buffer
+	if err := printOutdated(&buf, outputTable, infos); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(buf.String(), "patch (minor outside constraint)") {
+		t.Errorf("unexpected output:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	if err := printOutdated(&buf, outputJSON, infos); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(buf.String(), `"wanted_gap": "patch"`) {
+		t.Errorf("unexpected output:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	ver "github.com/hashicorp/go-version"
+)
+
+// project holds settings that apply to all directories matching Path. Path
+// is a glob (see filepath.Match); a directory matches if it or one of its
+// parents matches the glob, so `~/work/live/*` covers every directory below
+// `~/work/live/`. A relative glob such as `live/*` matches the trailing
+// components of a directory, wherever it is located.
+type project struct {
+	Path    string `yaml:"path"`
+	Tool    string `yaml:"tool"`
+	envConf `yaml:",inline"`
+}
+
+func (p project) matches(dir string) bool {
+	return matchPath(p.Path, dir)
+}
+
+func matchPath(pattern, dir string) bool {
+	pattern, err := expandPath(pattern)
+	if err != nil || pattern == "" {
+		return false
+	}
+	pattern = filepath.Clean(pattern)
+	relative := !filepath.IsAbs(pattern)
+
+	for d := filepath.Clean(dir); ; d = filepath.Dir(d) {
+		if ok, _ := filepath.Match(pattern, d); ok {
+			return true
+		}
+		if relative && matchSuffix(pattern, d) {
+			return true
+		}
+		if d == filepath.Dir(d) {
+			return false
+		}
+	}
+}
+
+// matchSuffix reports whether the trailing components of dir match the
+// relative pattern.
+func matchSuffix(pattern, dir string) bool {
+	for s := dir; ; {
+		i := strings.IndexRune(s, filepath.Separator)
+		if i < 0 {
+			return false
+		}
+		s = s[i+1:]
+		if ok, _ := filepath.Match(pattern, s); ok {
+			return true
+		}
+	}
+}
+
+// projectsFor returns all projects matching dir in the order of the
+// configuration file.
+func (c *conf) projectsFor(dir string) []project {
+	out := []project{}
+	for _, p := range c.Projects {
+		if p.matches(dir) {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// toolFor determines which tool is used in dir. A matching project takes
+// precedence over a tool specific version file (e.g. .opentofu-version),
+// which takes precedence over the globally configured tool.
+func (c *conf) toolFor(dir string) (Tool, error) {
+	for _, p := range c.projectsFor(dir) {
+		if p.Tool != "" {
+			return c.getTool(p.Tool)
+		}
+	}
+
+	for _, name := range c.toolNames() {
+		t, err := c.getTool(name)
+		if err != nil {
+			return t, err
+		}
+		if !t.Detect || t.VersionFile == "" {
+			continue
+		}
+		if _, found := findUp(dir, t.VersionFile); found {
+			return t, nil
+		}
+	}
+
+	if c.Tool != "" {
+		return c.getTool(c.Tool)
+	}
+	return c.getTool(defaultTool)
+}
+
+// findUp looks for a file named name in dir and its parents.
+func findUp(dir, name string) (string, bool) {
+	for d := filepath.Clean(dir); ; d = filepath.Dir(d) {
+		filename := filepath.Join(d, name)
+		if info, err := os.Stat(filename); err == nil && !info.IsDir() {
+			return filename, true
+		}
+		if d == filepath.Dir(d) {
+			return "", false
+		}
+	}
+}
+
+// resolveConstraint reads the version constraint of a tool in dir. A tool
+// specific version file wins over the `required_version` in the tool's HCL
+// configuration files (e.g. versions.tf).
+//
+// Inside a Terragrunt cache directory (where Terragrunt runs terraform on a
+// copy of the module) the configuration files of the directory Terragrunt
+// was run in are consulted if the module copy does not declare a version.
+func resolveConstraint(t Tool, dir string) (ver.Constraints, error) {
+	c, _, err := findConstraint(t, dir)
+	return c, err
+}
+
+// findConstraint is resolveConstraint which also returns the file the
+// constraint was read from, or an empty string if there is none.
+func findConstraint(t Tool, dir string) (ver.Constraints, string, error) {
+	if t.VersionFile != "" {
+		if filename, found := findUp(dir, t.VersionFile); found {
+			c, err := readVersionFile(filename)
+			return c, filename, err
+		}
+	}
+	if t.ConfigFiles == "" {
+		return ver.Constraints{}, "", nil
+	}
+
+	attribute := t.ConfigAttribute
+	if attribute == "" {
+		attribute = "required_version"
+	}
+	c, filename, err := findRequiredVersion(dir, t.ConfigFiles, t.ConfigBlock, attribute)
+	if err != nil || len(c) > 0 {
+		return c, filename, err
+	}
+	if tgDir, ok := terragruntDir(dir); ok {
+		c, filename, err := findRequiredVersion(tgDir, t.ConfigFiles, t.ConfigBlock, attribute)
+		if err != nil || len(c) > 0 || t.ConfigBlock != "terraform" {
+			return c, filename, err
+		}
+		// stacks usually pin terraform in terragrunt.hcl
+		return findRequiredVersion(tgDir, terragruntConfigFile, "", terragruntTerraformAttribute)
+	}
+	return c, "", nil
+}
+
+const (
+	// terragruntCacheDir is the directory Terragrunt copies modules to.
+	terragruntCacheDir = ".terragrunt-cache"
+	// terragruntConfigFile is the configuration file of Terragrunt, which
+	// may constrain the version of terraform with the top level attribute
+	// terragruntTerraformAttribute.
+	terragruntConfigFile         = "terragrunt.hcl"
+	terragruntTerraformAttribute = "terraform_version_constraint"
+)
+
+// terragruntDir returns the directory containing the Terragrunt cache
+// directory if dir is located within it.
+func terragruntDir(dir string) (string, bool) {
+	for d := filepath.Clean(dir); d != filepath.Dir(d); d = filepath.Dir(d) {
+		if filepath.Base(d) == terragruntCacheDir {
+			return filepath.Dir(d), true
+		}
+	}
+	return "", false
+}
+
+// readVersionFile parses files such as .opentofu-version which contain a
+// single version or constraint.
+func readVersionFile(filename string) (ver.Constraints, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return ver.Constraints{}, err
+	}
+	content := strings.TrimSpace(string(data))
+	if content == "" {
+		return ver.Constraints{}, nil
+	}
+	line, _, _ := strings.Cut(content, "\n")
+	c, err := ver.NewConstraint(strings.TrimSpace(line))
+	if err != nil {
+		return c, fmt.Errorf("invalid version in %s: %w", filename, err)
+	}
+	return c, nil
+}
@@ -0,0 +1,449 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMatchPath(t *testing.T) {
+	tests := []struct {
+		name     string
+		pattern  string
+		dir      string
+		expected bool
+	}{
+		{
+			name:     "exact directory",
+			pattern:  "/work/live",
+			dir:      "/work/live",
+			expected: true,
+		},
+		{
+			name:     "subdirectory of matching directory",
+			pattern:  "/work/live",
+			dir:      "/work/live/network/vpc",
+			expected: true,
+		},
+		{
+			name:     "glob matches subdirectory",
+			pattern:  "/work/*/network",
+			dir:      "/work/live/network/vpc",
+			expected: true,
+		},
+		{
+			name:     "sibling does not match",
+			pattern:  "/work/live",
+			dir:      "/work/staging",
+			expected: false,
+		},
+		{
+			name:     "prefix of name does not match",
+			pattern:  "/work/live",
+			dir:      "/work/live-old",
+			expected: false,
+		},
+		{
+			name:     "relative pattern matches trailing components",
+			pattern:  "live/*",
+			dir:      "/work/repo/live/network/vpc",
+			expected: true,
+		},
+		{
+			name:     "relative pattern matches whole components only",
+			pattern:  "live",
+			dir:      "/work/repo/deliver/vpc",
+			expected: false,
+		},
+		{
+			name:     "empty pattern never matches",
+			pattern:  "",
+			dir:      "/work/live",
+			expected: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := matchPath(tt.pattern, tt.dir); result != tt.expected {
+				t.Errorf("matchPath(%q, %q) = %v, want %v", tt.pattern, tt.dir, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestToolFor(t *testing.T) {
+	tests := []struct {
+		name        string
+		conf        conf
+		versionFile string
+		expected    string
+		expectError bool
+	}{
+		{
+			name:     "defaults to terraform",
+			expected: "terraform",
+		},
+		{
+			name:     "uses globally configured tool",
+			conf:     conf{Tool: "tofu"},
+			expected: "tofu",
+		},
+		{
+			name:        "version file wins over global tool",
+			conf:        conf{Tool: "terraform"},
+			versionFile: ".opentofu-version",
+			expected:    "tofu",
+		},
+		{
+			name: "matching project wins over version file",
+			conf: conf{Projects: []project{
+				{Path: "/", Tool: "terraform"},
+			}},
+			versionFile: ".opentofu-version",
+			expected:    "terraform",
+		},
+		{
+			name: "project without tool is skipped",
+			conf: conf{Tool: "tofu", Projects: []project{
+				{Path: "/"},
+			}},
+			expected: "tofu",
+		},
+		{
+			name:        "unknown tool returns error",
+			conf:        conf{Tool: "nope"},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tt.versionFile != "" {
+				if err := os.WriteFile(filepath.Join(dir, tt.versionFile), []byte("1.6.0\n"), 0644); err != nil {
+					t.Fatalf("could not write version file: %v", err)
+				}
+			}
+
+			tool, err := tt.conf.toolFor(dir)
+			if tt.expectError {
+				if err == nil {
+					t.Error("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tool.Name != tt.expected {
+				t.Errorf("toolFor() = %q, want %q", tool.Name, tt.expected)
+			}
+		})
+	}
+}
+
+func TestResolveConstraint(t *testing.T) {
+	k := &conf{}
+	tofu, err := k.getTool("tofu")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	terraform, err := k.getTool("terraform")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	packer, err := k.getTool("packer")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	terragrunt, err := k.getTool("terragrunt")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name               string
+		tool               Tool
+		versionFile        string
+		configFile         string
+		config             string
+		expectedConstraint string
+		expectError        bool
+	}{
+		{
+			name:               "reads required_version",
+			tool:               tofu,
+			config:             `terraform { required_version = "~> 1.6" }`,
+			expectedConstraint: "~> 1.6",
+		},
+		{
+			name:               "version file wins over required_version",
+			tool:               tofu,
+			versionFile:        "1.6.2\n",
+			config:             `terraform { required_version = "~> 1.6" }`,
+			expectedConstraint: "1.6.2",
+		},
+		{
+			name:               "version file may contain a constraint",
+			tool:               tofu,
+			versionFile:        ">= 1.7.0, < 1.8.0",
+			expectedConstraint: ">= 1.7.0, < 1.8.0",
+		},
+		{
+			name:               "version file of other tool is ignored",
+			tool:               terraform,
+			versionFile:        "1.6.2",
+			config:             `terraform { required_version = "~> 1.5" }`,
+			expectedConstraint: "~> 1.5",
+		},
+		{
+			name:        "invalid version file returns error",
+			tool:        tofu,
+			versionFile: "latest-ish",
+			expectError: true,
+		},
+		{
+			name:       "reads packer block from pkr.hcl files",
+			tool:       packer,
+			configFile: "build.pkr.hcl",
+			config: `
+packer {
+  required_version = ">= 1.10.0"
+}
+
+source "amazon-ebs" "base" {
+  region = var.region
+}
+`,
+			expectedConstraint: ">= 1.10.0",
+		},
+		{
+			name:               "terraform block is ignored for packer",
+			tool:               packer,
+			configFile:         "build.pkr.hcl",
+			config:             `terraform { required_version = "~> 1.5" }`,
+			expectedConstraint: "",
+		},
+		{
+			name:       "reads top level terragrunt_version_constraint",
+			tool:       terragrunt,
+			configFile: "terragrunt.hcl",
+			config: `
+terragrunt_version_constraint = ">= 0.55"
+
+include "root" {
+  path = find_in_parent_folders()
+}
+
+inputs = {
+  name = local.name
+}
+`,
+			expectedConstraint: ">= 0.55",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			dir := filepath.Join(root, "stack")
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				t.Fatalf("could not create dir: %v", err)
+			}
+			// version files are searched in parent directories
+			if tt.versionFile != "" {
+				if err := os.WriteFile(filepath.Join(root, ".opentofu-version"), []byte(tt.versionFile), 0644); err != nil {
+					t.Fatalf("could not write version file: %v", err)
+				}
+			}
+			if tt.configFile == "" {
+				tt.configFile = "versions.tf"
+			}
+			if tt.config != "" {
+				if err := os.WriteFile(filepath.Join(dir, tt.configFile), []byte(tt.config), 0644); err != nil {
+					t.Fatalf("could not write %s: %v", tt.configFile, err)
+				}
+			}
+
+			c, source, err := findConstraint(tt.tool, dir)
+			if tt.expectError {
+				if err == nil {
+					t.Error("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if c.String() != tt.expectedConstraint {
+				t.Errorf("resolveConstraint() = %q, want %q", c.String(), tt.expectedConstraint)
+			}
+			if (len(c) > 0) != (source != "") {
+				t.Errorf("constraint %q read from %q", c.String(), source)
+			}
+			if base := filepath.Base(source); source != "" && base != ".opentofu-version" && base != tt.configFile {
+				t.Errorf("unexpected constraint source %q", source)
+			}
+		})
+	}
+}
+
+func TestResolveConstraintInTerragruntCache(t *testing.T) {
+	terraform := knownTools["terraform"]
+
+	terragruntHcl := `
+include "root" {
+  path = find_in_parent_folders()
+}
+
+terraform_version_constraint = ">= 1.4.0"
+`
+
+	tests := []struct {
+		name               string
+		moduleVersionsTf   string
+		stackVersionsTf    string
+		terragruntHcl      string
+		expectedConstraint string
+	}{
+		{
+			name:               "module copy declares a version",
+			moduleVersionsTf:   `terraform { required_version = "~> 1.6.0" }`,
+			stackVersionsTf:    `terraform { required_version = "~> 1.5.0" }`,
+			terragruntHcl:      terragruntHcl,
+			expectedConstraint: "~> 1.6.0",
+		},
+		{
+			name:               "falls back to the terragrunt directory",
+			stackVersionsTf:    `terraform { required_version = "~> 1.5.0" }`,
+			terragruntHcl:      terragruntHcl,
+			expectedConstraint: "~> 1.5.0",
+		},
+		{
+			name:               "falls back to terraform_version_constraint",
+			terragruntHcl:      terragruntHcl,
+			expectedConstraint: ">= 1.4.0",
+		},
+		{
+			name:               "no constraint",
+			terragruntHcl:      `include "root" { path = find_in_parent_folders() }`,
+			expectedConstraint: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stack := t.TempDir()
+			if tt.stackVersionsTf != "" {
+				if err := os.WriteFile(filepath.Join(stack, "versions.tf"), []byte(tt.stackVersionsTf), 0644); err != nil {
+					t.Fatalf("could not write versions.tf: %v", err)
+				}
+			}
+			if err := os.WriteFile(filepath.Join(stack, terragruntConfigFile), []byte(tt.terragruntHcl), 0644); err != nil {
+				t.Fatalf("could not write terragrunt.hcl: %v", err)
+			}
+			module := filepath.Join(stack, terragruntCacheDir, "abc", "def", "modules", "vpc")
+			if err := os.MkdirAll(module, 0755); err != nil {
+				t.Fatalf("could not create dir: %v", err)
+			}
+			if tt.moduleVersionsTf != "" {
+				if err := os.WriteFile(filepath.Join(module, "versions.tf"), []byte(tt.moduleVersionsTf), 0644); err != nil {
+					t.Fatalf("could not write versions.tf: %v", err)
+				}
+			}
+
+			c, err := resolveConstraint(terraform, module)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if c.String() != tt.expectedConstraint {
+				t.Errorf("resolveConstraint() = %q, want %q", c.String(), tt.expectedConstraint)
+			}
+		})
+	}
+}
+
+func TestTerragruntDir(t *testing.T) {
+	tests := []struct {
+		dir      string
+		expected string
+		found    bool
+	}{
+		{dir: "/live/vpc/.terragrunt-cache/abc/def", expected: "/live/vpc", found: true},
+		{dir: "/live/vpc/.terragrunt-cache", expected: "/live/vpc", found: true},
+		{dir: "/live/vpc", found: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.dir, func(t *testing.T) {
+			result, found := terragruntDir(tt.dir)
+			if result != tt.expected || found != tt.found {
+				t.Errorf("terragruntDir(%q) = %q, %v, want %q, %v", tt.dir, result, found, tt.expected, tt.found)
+			}
+		})
+	}
+}
+
+func TestGetTool(t *testing.T) {
+	k := &conf{Tools: map[string]Tool{
+		"boundary": {VersionFile: ".boundary-version"},
+		"vault":    {Releases: releasesGitHub, Repo: "example/vault"},
+		"broken":   {Releases: releasesGitHub},
+	}}
+
+	tests := []struct {
+		name             string
+		tool             string
+		expectedReleases string
+		expectError      bool
+	}{
+		{name: "built-in tool", tool: "packer", expectedReleases: releasesHashiCorp},
+		{name: "configured tool defaults to hashicorp releases", tool: "boundary", expectedReleases: releasesHashiCorp},
+		{name: "configured tool overrides built-in tool", tool: "vault", expectedReleases: releasesGitHub},
+		{name: "github releases require a repo", tool: "broken", expectError: true},
+		{name: "unknown tool", tool: "nope", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tool, err := k.getTool(tt.tool)
+			if tt.expectError {
+				if err == nil {
+					t.Error("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tool.Name != tt.tool {
+				t.Errorf("Name = %q, want %q", tool.Name, tt.tool)
+			}
+			if tool.Releases != tt.expectedReleases {
+				t.Errorf("Releases = %q, want %q", tool.Releases, tt.expectedReleases)
+			}
+		})
+	}
+}
+
+func TestSplitToolVersion(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedTool    string
+		expectedVersion string
+	}{
+		{input: "1.6.2", expectedTool: "", expectedVersion: "1.6.2"},
+		{input: "tofu@1.6.2", expectedTool: "tofu", expectedVersion: "1.6.2"},
+		{input: "terraform@", expectedTool: "terraform", expectedVersion: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			tool, version := splitToolVersion(tt.input)
+			if tool != tt.expectedTool || version != tt.expectedVersion {
+				t.Errorf("splitToolVersion(%q) = %q, %q, want %q, %q", tt.input, tool, version, tt.expectedTool, tt.expectedVersion)
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// secretSource declares where the value of a secret comes from. Exactly
+// one of the fields must be set.
+type secretSource struct {
+	// Command is run with `sh -c` (`cmd /C` on Windows), its output is the
+	// value.
+	Command string `yaml:"command"`
+	// File holds the value. Relative paths are looked up in the working
+	// directory.
+	File string `yaml:"file"`
+	// Pass and Gopass are entries of the pass and gopass password stores.
+	// The value is the first line of the entry, i.e. the password.
+	Pass   string `yaml:"pass"`
+	Gopass string `yaml:"gopass"`
+}
+
+func (s secretSource) String() string {
+	switch {
+	case s.Command != "":
+		return "command"
+	case s.File != "":
+		return "file"
+	case s.Pass != "":
+		return "pass"
+	case s.Gopass != "":
+		return "gopass"
+	default:
+		return "none"
+	}
+}
+
+// secretVar is an environment variable whose value is a secret.
+type secretVar struct {
+	Name   string
+	Source secretSource
+}
+
+// secretVars keeps the order of the configuration.
+type secretVars []secretVar
+
+func (s *secretVars) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: secrets must be a mapping of names to sources", node.Line)
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		var source secretSource
+		if err := node.Content[i+1].Decode(&source); err != nil {
+			return err
+		}
+		*s = append(*s, secretVar{Name: node.Content[i].Value, Source: source})
+	}
+	return nil
+}
+
+// resolve reads the value of the secret. Commands are run in dir with the
+// environment env; their stderr is passed on so password prompts work.
+func (s secretSource) resolve(dir string, env []string) (string, error) {
+	set := 0
+	for _, v := range []string{s.Command, s.File, s.Pass, s.Gopass} {
+		if v != "" {
+			set++
+		}
+	}
+	if set != 1 {
+		return "", fmt.Errorf("exactly one of command, file, pass or gopass must be set")
+	}
+
+	switch {
+	case s.File != "":
+		filename, err := expandPath(s.File)
+		if err != nil {
+			return "", err
+		}
+		if !filepath.IsAbs(filename) {
+			filename = filepath.Join(dir, filename)
+		}
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	case s.Command != "":
+		shell, flag := "sh", "-c"
+		if runtime.GOOS == "windows" {
+			shell, flag = "cmd", "/C"
+		}
+		out, err := runSecretCommand(dir, env, shell, flag, s.Command)
+		return strings.TrimRight(out, "\r\n"), err
+	case s.Pass != "":
+		out, err := runSecretCommand(dir, env, "pass", "show", s.Pass)
+		return firstLine(out), err
+	default:
+		out, err := runSecretCommand(dir, env, "gopass", "show", "--password", s.Gopass)
+		return firstLine(out), err
+	}
+}
+
+func runSecretCommand(dir string, env []string, name string, args ...string) (string, error) {
+	var out bytes.Buffer
+	cmd := exec.Command(name, args...)
+	cmd.Dir = dir
+	cmd.Env = env
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = &out
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("%s failed: %w", name, err)
+	}
+	return out.String(), nil
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return strings.TrimRight(line, "\r")
+}
+
+// redactor replaces secret values in output of wtf.
+type redactor struct {
+	values []string
+}
+
+func (r *redactor) add(value string) {
+	if value == "" {
+		return
+	}
+	r.values = append(r.values, value)
+	// longer values first, so a secret containing another one is
+	// replaced as a whole
+	sort.Slice(r.values, func(i, j int) bool {
+		return len(r.values[i]) > len(r.values[j])
+	})
+}
+
+// redact replaces every secret value in s with `***`. A nil redactor
+// returns s unchanged.
+func (r *redactor) redact(s string) string {
+	if r == nil {
+		return s
+	}
+	for _, v := range r.values {
+		s = strings.ReplaceAll(s, v, "***")
+	}
+	return s
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// fakeCommand creates an executable shell script named name in a directory
+// added to PATH.
+func fakeCommand(t *testing.T, name, script string) {
+	t.Helper()
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no POSIX shell available")
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+script), 0755); err != nil {
+		t.Fatalf("could not write %s: %v", name, err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestSecretSourceResolve(t *testing.T) {
+	fakeCommand(t, "pass", `[ "$1 $2" = "show infra/db" ] && printf 'p4ss\nuser: admin\n'`)
+	fakeCommand(t, "gopass", `[ "$1 $2 $3" = "show --password infra/api" ] && echo g0pass`)
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "token"), []byte("t0ken\n"), 0600); err != nil {
+		t.Fatalf("could not write file: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		source      secretSource
+		expected    string
+		expectError bool
+	}{
+		{name: "command", source: secretSource{Command: "echo \"$WTF_TEST_PREFIX-secret\""}, expected: "prefix-secret"},
+		{name: "file", source: secretSource{File: "token"}, expected: "t0ken"},
+		{name: "pass", source: secretSource{Pass: "infra/db"}, expected: "p4ss"},
+		{name: "gopass", source: secretSource{Gopass: "infra/api"}, expected: "g0pass"},
+		{name: "failing command", source: secretSource{Command: "exit 1"}, expectError: true},
+		{name: "missing file", source: secretSource{File: "missing"}, expectError: true},
+		{name: "no source", source: secretSource{}, expectError: true},
+		{name: "two sources", source: secretSource{File: "token", Pass: "infra/db"}, expectError: true},
+	}
+
+	env := append(os.Environ(), "WTF_TEST_PREFIX=prefix")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, err := tt.source.resolve(dir, env)
+			if tt.expectError {
+				if err == nil {
+					t.Error("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if value != tt.expected {
+				t.Errorf("resolve() = %q, want %q", value, tt.expected)
+			}
+		})
+	}
+}
+
+func TestEnvironmentSecrets(t *testing.T) {
+	fakeCommand(t, "pass", `echo "secret-of-$2"`)
+	root := t.TempDir()
+	dir := filepath.Join(root, "live", "prod")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("could not create directory: %v", err)
+	}
+
+	content := `
+env:
+  STORE: global
+secrets:
+  TF_VAR_global:
+    pass: ${STORE}
+projects:
+  - path: live/*
+    secrets:
+      TF_VAR_db_password:
+        command: echo "db-$TF_VAR_global"
+  - path: other
+    secrets:
+      TF_VAR_other:
+        pass: other
+`
+	k := NewConfigurationDefaults()
+	if err := yaml.Unmarshal([]byte(content), k); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	env, secrets, err := k.environment(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	values := newEnvironment(env)
+	if v, _ := values.lookup("TF_VAR_global"); v != "secret-of-${STORE}" {
+		// secret sources are not interpolated
+		t.Errorf("TF_VAR_global = %q", v)
+	}
+	if v, _ := values.lookup("TF_VAR_db_password"); v != "db-secret-of-${STORE}" {
+		t.Errorf("TF_VAR_db_password = %q", v)
+	}
+	if _, ok := values.lookup("TF_VAR_other"); ok {
+		t.Error("secret of other project should not be set")
+	}
+
+	redacted := secrets.redact("password is db-secret-of-${STORE}, global is secret-of-${STORE}")
+	if redacted != "password is ***, global is ***" {
+		t.Errorf("redact() = %q", redacted)
+	}
+	if names := strings.Join(k.secretNames(dir), ", "); names != "TF_VAR_global (pass), TF_VAR_db_password (command)" {
+		t.Errorf("secretNames() = %q", names)
+	}
+
+	k.Secrets[0].Source.Pass = ""
+	if _, _, err := k.environment(dir); err == nil || !strings.Contains(err.Error(), "secret TF_VAR_global") {
+		t.Errorf("expected error naming the secret, got %v", err)
+	}
+}
+
+func TestRedactor(t *testing.T) {
+	var none *redactor
+	if s := none.redact("unchanged"); s != "unchanged" {
+		t.Errorf("nil redactor changed %q", s)
+	}
+
+	r := &redactor{}
+	r.add("")
+	r.add("abc")
+	r.add("abcdef")
+	if s := r.redact("x abcdef y abc z"); s != "x *** y *** z" {
+		t.Errorf("redact() = %q", s)
+	}
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// shimNames returns the tools a shim is created for by default: the global
+// tool, the tools of projects and the tools of the `tools` section.
+func (c *conf) shimNames() []string {
+	seen := map[string]bool{}
+	add := func(name string) {
+		if name != "" {
+			seen[name] = true
+		}
+	}
+	add(c.Tool)
+	for _, p := range c.Projects {
+		add(p.Tool)
+	}
+	for name := range c.Tools {
+		add(name)
+	}
+	if len(seen) == 0 {
+		add(defaultTool)
+	}
+
+	names := []string{}
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// wtfExecutable returns the path shims point at: the path wtf was started
+// from, e.g. ~/.nix-profile/bin/wtf or /opt/homebrew/bin/wtf, rather than
+// the versioned file it links to, so shims keep working when a package
+// manager upgrades wtf.
+func wtfExecutable() (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	invoked := os.Args[0]
+	if !strings.ContainsAny(invoked, `/\`) {
+		if invoked, err = exec.LookPath(invoked); err != nil {
+			return exe, nil
+		}
+	}
+	return stableExecutable(invoked, exe), nil
+}
+
+// stableExecutable returns invoked if it is a path of the running binary
+// exe named wtf, and exe otherwise. A shim is never returned, so shims do
+// not point at each other.
+func stableExecutable(invoked, exe string) string {
+	invoked, err := filepath.Abs(invoked)
+	if err != nil || !isWtf(invoked) || !sameFile(invoked, exe) {
+		return exe
+	}
+	return invoked
+}
+
+func isWtf(filename string) bool {
+	return strings.TrimSuffix(filepath.Base(filename), ".exe") == "wtf"
+}
+
+// isStaleShim reports whether filename is a symlink left behind by an
+// older wtf: it points at another binary named wtf, which may be gone, e.g.
+// after a package manager removed the version it pointed at. Links to other
+// binaries are never stale, even if their target is gone.
+func isStaleShim(filename string) bool {
+	target, err := os.Readlink(filename)
+	return err == nil && isWtf(target)
+}
+
+// shimFile returns the name of the shim of a tool in dir.
+func shimFile(dir, name string) string {
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	return filepath.Join(dir, name)
+}
+
+// sameFile reports whether a and b, after following symlinks, are the same
+// file.
+func sameFile(a, b string) bool {
+	ai, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	bi, err := os.Stat(b)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(ai, bi)
+}
+
+// installShim creates a shim for the tool name in dir pointing at wtf. An
+// existing shim is kept and a stale one replaced, any other existing file
+// is an error.
+func installShim(dir, name, wtf string) (string, bool, error) {
+	filename := shimFile(dir, name)
+	if _, err := os.Lstat(filename); err == nil {
+		switch {
+		case sameFile(filename, wtf):
+			return filename, false, nil
+		case isStaleShim(filename):
+			if err := os.Remove(filename); err != nil {
+				return filename, false, err
+			}
+		default:
+			return filename, false, fmt.Errorf("%s already exists and is not a shim of wtf", filename)
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return filename, false, err
+	}
+	err := os.Symlink(wtf, filename)
+	if err != nil && runtime.GOOS == "windows" {
+		// creating symlinks requires developer mode or admin rights
+		err = os.Link(wtf, filename)
+	}
+	return filename, err == nil, err
+}
+
+// removeShim removes the shim of the tool name from dir, stale ones
+// included. Files which are not shims of wtf are left alone.
+func removeShim(dir, name, wtf string) (string, bool, error) {
+	filename := shimFile(dir, name)
+	if _, err := os.Lstat(filename); errors.Is(err, os.ErrNotExist) {
+		return filename, false, nil
+	}
+	if !sameFile(filename, wtf) && !isStaleShim(filename) {
+		return filename, false, fmt.Errorf("%s is not a shim of wtf", filename)
+	}
+	return filename, true, os.Remove(filename)
+}
+
+// shimStatus describes the shim of a tool and what running the tool
+// resolves to.
+type shimStatus struct {
+	Name string
+	// Shim is the path of the shim, Installed reports whether it exists.
+	Shim      string
+	Installed bool
+	// Stale reports whether the shim is left behind by an older wtf.
+	Stale bool
+	// InPath reports whether the directory of the shim is in PATH.
+	InPath bool
+	// Resolved is the first executable named like the tool in PATH, empty
+	// if there is none.
+	Resolved string
+	// Shadowed reports whether Resolved is a different binary than wtf.
+	Shadowed bool
+}
+
+func (s shimStatus) String() string {
+	switch {
+	case s.Stale:
+		return "stale"
+	case !s.Installed:
+		return "missing"
+	case !s.InPath:
+		return "not in PATH"
+	case s.Shadowed:
+		return "shadowed"
+	default:
+		return "ok"
+	}
+}
+
+func checkShim(dir, name, wtf, path string) shimStatus {
+	s := shimStatus{Name: name, Shim: shimFile(dir, name)}
+	s.Installed = sameFile(s.Shim, wtf)
+	s.Stale = !s.Installed && isStaleShim(s.Shim)
+	for _, d := range filepath.SplitList(path) {
+		if d == "" {
+			continue
+		}
+		if sameFile(d, dir) {
+			s.InPath = true
+		}
+		if s.Resolved == "" && isExecutable(shimFile(d, name)) {
+			s.Resolved = shimFile(d, name)
+		}
+	}
+	s.Shadowed = s.Resolved != "" && !sameFile(s.Resolved, wtf)
+	return s
+}
+
+func isExecutable(filename string) bool {
+	info, err := os.Stat(filename)
+	if err != nil || info.IsDir() {
+		return false
+	}
+	return runtime.GOOS == "windows" || info.Mode()&0111 != 0
+}
+
+// pathFix returns the commands putting dir first in PATH for the shell,
+// the base name of $SHELL. Shells remember where commands were found, so
+// the cache is cleared as well.
+func pathFix(dir, shell string) string {
+	switch {
+	case runtime.GOOS == "windows":
+		return fmt.Sprintf(`$env:Path = "%s;" + $env:Path`, dir)
+	case shell == "fish":
+		return fmt.Sprintf("fish_add_path --move --prepend %s", shellquote(dir))
+	default:
+		return fmt.Sprintf(`export PATH=%s:"$PATH"; hash -r`, shellquote(dir))
+	}
+}
+
+// reportShims logs every shim which does not take effect together with a
+// fix for the current shell.
+func reportShims(statuses []shimStatus, dir string) {
+	problems := 0
+	for _, s := range statuses {
+		switch {
+		case s.Stale:
+			logger.Warn(fmt.Sprintf("%s is left behind by an older wtf, run wtf shim install to replace it", s.Shim))
+			continue
+		case !s.Installed:
+			continue
+		case !s.InPath:
+			logger.Warn(fmt.Sprintf("%s is not in PATH, %s does not run the shim", dir, s.Name))
+		case s.Shadowed:
+			logger.Warn(fmt.Sprintf("%s is shadowed by %s earlier in PATH", s.Shim, s.Resolved))
+		default:
+			continue
+		}
+		problems++
+	}
+	if problems > 0 {
+		shell := strings.TrimSuffix(filepath.Base(os.Getenv("SHELL")), ".exe")
+		logger.Info("to fix this for the current shell, run: " + pathFix(dir, shell))
+		logger.Info("add it to the startup file of your shell to make it permanent")
+	}
+}
+
+func printShims(w io.Writer, statuses []shimStatus) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TOOL\tSHIM\tRESOLVES TO\tSTATUS")
+	for _, s := range statuses {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", s.Name, s.Shim, dash(s.Resolved), s)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestShimNames(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   string
+		expected []string
+	}{
+		{name: "default tool", config: "", expected: []string{"terraform"}},
+		{name: "global tool", config: "tool: tofu", expected: []string{"tofu"}},
+		{
+			name: "projects and tools",
+			config: `
+tool: terraform
+projects:
+  - path: live/*
+    tool: tofu
+  - path: other
+tools:
+  tflint:
+    releases: github
+    repo: terraform-linters/tflint
+`,
+			expected: []string{"terraform", "tflint", "tofu"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := NewConfigurationDefaults()
+			if err := yaml.Unmarshal([]byte(tt.config), k); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := k.shimNames(); strings.Join(got, " ") != strings.Join(tt.expected, " ") {
+				t.Errorf("shimNames() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+// fakeExecutable creates an executable file in a new directory.
+func fakeExecutable(t *testing.T, name string) string {
+	t.Helper()
+	filename := shimFile(t.TempDir(), name)
+	if err := os.WriteFile(filename, []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatalf("could not write %s: %v", filename, err)
+	}
+	return filename
+}
+
+func TestInstallRemoveShim(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks require developer mode on windows")
+	}
+	wtf := fakeExecutable(t, "wtf")
+	dir := filepath.Join(t.TempDir(), "bin")
+
+	filename, created, err := installShim(dir, "terraform", wtf)
+	if err != nil || !created {
+		t.Fatalf("installShim() = %v, %v, want shim to be created", created, err)
+	}
+	if target, err := os.Readlink(filename); err != nil || target != wtf {
+		t.Errorf("shim points at %q (%v), want %q", target, err, wtf)
+	}
+	if _, created, err := installShim(dir, "terraform", wtf); err != nil || created {
+		t.Errorf("installing again = %v, %v, want existing shim to be kept", created, err)
+	}
+
+	other := shimFile(dir, "tofu")
+	if err := os.WriteFile(other, []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, _, err := installShim(dir, "tofu", wtf); err == nil {
+		t.Error("expected error for existing file, got nil")
+	}
+	if _, _, err := removeShim(dir, "tofu", wtf); err == nil {
+		t.Error("expected error removing a file which is not a shim, got nil")
+	}
+	if _, err := os.Stat(other); err != nil {
+		t.Errorf("file which is not a shim was removed: %v", err)
+	}
+
+	if _, removed, err := removeShim(dir, "terraform", wtf); err != nil || !removed {
+		t.Errorf("removeShim() = %v, %v, want shim to be removed", removed, err)
+	}
+	if _, err := os.Lstat(filename); !os.IsNotExist(err) {
+		t.Errorf("shim still exists: %v", err)
+	}
+	if _, removed, err := removeShim(dir, "terraform", wtf); err != nil || removed {
+		t.Errorf("removing a missing shim = %v, %v, want nothing to happen", removed, err)
+	}
+}
+
+func TestCheckShim(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks require developer mode on windows")
+	}
+	wtf := fakeExecutable(t, "wtf")
+	dir := t.TempDir()
+	if _, _, err := installShim(dir, "terraform", wtf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	other := fakeExecutable(t, "terraform")
+	otherDir := filepath.Dir(other)
+	sep := string(os.PathListSeparator)
+
+	tests := []struct {
+		name     string
+		tool     string
+		path     string
+		resolved string
+		expected string
+	}{
+		{name: "first in PATH", tool: "terraform", path: dir + sep + otherDir, resolved: shimFile(dir, "terraform"), expected: "ok"},
+		{name: "shadowed", tool: "terraform", path: otherDir + sep + dir, resolved: other, expected: "shadowed"},
+		{name: "not in PATH", tool: "terraform", path: otherDir, resolved: other, expected: "not in PATH"},
+		{name: "missing", tool: "tofu", path: dir, expected: "missing"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := checkShim(dir, tt.tool, wtf, tt.path)
+			if s.String() != tt.expected {
+				t.Errorf("status = %s, want %s", s, tt.expected)
+			}
+			if s.Resolved != tt.resolved {
+				t.Errorf("resolved = %q, want %q", s.Resolved, tt.resolved)
+			}
+		})
+	}
+}
+
+func TestPathFix(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("PowerShell is suggested on windows")
+	}
+	tests := []struct {
+		shell    string
+		expected string
+	}{
+		{shell: "bash", expected: `export PATH='/home/my user/bin':"$PATH"; hash -r`},
+		{shell: "zsh", expected: `export PATH='/home/my user/bin':"$PATH"; hash -r`},
+		{shell: "fish", expected: `fish_add_path --move --prepend '/home/my user/bin'`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.shell, func(t *testing.T) {
+			if got := pathFix("/home/my user/bin", tt.shell); got != tt.expected {
+				t.Errorf("pathFix() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestStableExecutable(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks require developer mode on windows")
+	}
+	store := fakeExecutable(t, "wtf")
+	bin := t.TempDir()
+	profile := filepath.Join(bin, "wtf")
+	if err := os.Symlink(store, profile); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	shim := filepath.Join(bin, "terraform")
+	if err := os.Symlink(profile, shim); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		invoked  string
+		expected string
+	}{
+		{name: "link of a package manager", invoked: profile, expected: profile},
+		{name: "binary itself", invoked: store, expected: store},
+		{name: "shim", invoked: shim, expected: store},
+		{name: "other binary", invoked: fakeExecutable(t, "wtf"), expected: store},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := stableExecutable(tt.invoked, store); got != tt.expected {
+				t.Errorf("stableExecutable() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestStaleShims(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks require developer mode on windows")
+	}
+	wtf := fakeExecutable(t, "wtf")
+	oldWtf := fakeExecutable(t, "wtf")
+	dir := t.TempDir()
+
+	links := map[string]string{
+		// an old version removed by the package manager
+		"terraform": filepath.Join(t.TempDir(), "gone", "wtf"),
+		// another installation of wtf
+		"tofu": oldWtf,
+		// a link to something else
+		"packer": fakeExecutable(t, "packer"),
+		// a link of an uninstalled version manager
+		"terragrunt": filepath.Join(t.TempDir(), ".tfenv", "bin", "terragrunt"),
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, shimFile(dir, name)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	for _, name := range []string{"terraform", "tofu"} {
+		if s := checkShim(dir, name, wtf, dir); s.String() != "stale" {
+			t.Errorf("status of %s = %s, want stale", name, s)
+		}
+	}
+	for _, name := range []string{"packer", "terragrunt"} {
+		if s := checkShim(dir, name, wtf, dir); s.String() != "missing" {
+			t.Errorf("status of %s = %s, want missing", name, s)
+		}
+	}
+
+	if _, created, err := installShim(dir, "terraform", wtf); err != nil || !created {
+		t.Errorf("installShim() = %v, %v, want stale shim to be replaced", created, err)
+	}
+	if s := checkShim(dir, "terraform", wtf, dir); s.String() != "ok" {
+		t.Errorf("status after install = %s, want ok", s)
+	}
+	if _, removed, err := removeShim(dir, "tofu", wtf); err != nil || !removed {
+		t.Errorf("removeShim() = %v, %v, want stale shim to be removed", removed, err)
+	}
+	for _, name := range []string{"packer", "terragrunt"} {
+		if _, _, err := installShim(dir, name, wtf); err == nil {
+			t.Errorf("expected error replacing the link %s to another binary, got nil", name)
+		}
+		if _, _, err := removeShim(dir, name, wtf); err == nil {
+			t.Errorf("expected error removing the link %s to another binary, got nil", name)
+		}
+		if _, err := os.Lstat(shimFile(dir, name)); err != nil {
+			t.Errorf("link %s should be left alone: %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"time"
+)
+
+// signalConf configures how wtf passes signals on to the tool. After the
+// first signal, the tool has GracePeriod to exit before it is killed; zero
+// waits for the tool indefinitely.
+type signalConf struct {
+	GracePeriod time.Duration `yaml:"grace_period"`
+}
+
+// forwarder passes the signals wtf receives while the tool runs on to the
+// tool, so it can release state locks and exit cleanly instead of being
+// orphaned when wtf is stopped.
+type forwarder struct {
+	proc     *os.Process
+	group    bool
+	terminal bool
+	grace    time.Duration
+	sigs     chan os.Signal
+	done     chan struct{}
+}
+
+// forwardSignals starts forwarding to proc, or to its process group if
+// group is set. If terminal is set, the tool shares the terminal with wtf
+// and receives keyboard signals such as Ctrl-C directly, so they are not
+// passed on a second time. Call stop once the tool has exited.
+func forwardSignals(proc *os.Process, group, terminal bool, grace time.Duration, sigs chan os.Signal) *forwarder {
+	f := &forwarder{proc: proc, group: group, terminal: terminal, grace: grace, sigs: sigs, done: make(chan struct{})}
+	go f.loop()
+	return f
+}
+
+func isTerminalSignal(sig os.Signal) bool {
+	for _, s := range terminalSignals {
+		if sig == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *forwarder) loop() {
+	var kill <-chan time.Time
+	for {
+		select {
+		case sig := <-f.sigs:
+			if f.terminal && isTerminalSignal(sig) {
+				continue
+			}
+			_ = signalProcess(f.proc, f.group, sig)
+			if kill == nil && f.grace > 0 {
+				kill = time.After(f.grace)
+			}
+		case <-kill:
+			_ = killProcess(f.proc, f.group)
+			kill = nil
+		case <-f.done:
+			return
+		}
+	}
+}
+
+func (f *forwarder) stop() {
+	signal.Stop(f.sigs)
+	close(f.done)
+}
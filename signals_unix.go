@@ -0,0 +1,53 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// shutdownSignals are the signals passed on to the tool.
+var shutdownSignals = []os.Signal{os.Interrupt, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGQUIT}
+
+// terminalSignals are sent by the terminal to every process of its
+// foreground job.
+var terminalSignals = []os.Signal{os.Interrupt, syscall.SIGQUIT}
+
+// processGroup returns the attributes the tool is started with and
+// reports whether it shares the terminal with wtf.
+//
+// If wtf is the foreground job of a terminal, the tool stays in the process
+// group of wtf, so job control (Ctrl-Z, pipes into a pager) works as if
+// the tool was run directly, and keys such as Ctrl-C reach the tool from
+// the terminal. Otherwise, e.g. in CI, the tool gets a process group of its
+// own, so a signal sent to the group reaches the tool started by a wrapper
+// script as well.
+func processGroup() (*syscall.SysProcAttr, bool) {
+	pgrp, err := foregroundGroup(int(os.Stdin.Fd()))
+	if err == nil && pgrp == syscall.Getpgrp() {
+		return &syscall.SysProcAttr{}, true
+	}
+	return &syscall.SysProcAttr{Setpgid: true}, false
+}
+
+func foregroundGroup(fd int) (int, error) {
+	var pgrp int32
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), uintptr(syscall.TIOCGPGRP), uintptr(unsafe.Pointer(&pgrp))); errno != 0 {
+		return 0, errno
+	}
+	return int(pgrp), nil
+}
+
+// signalProcess sends sig to proc, or to every process in its group.
+func signalProcess(proc *os.Process, group bool, sig os.Signal) error {
+	if !group {
+		return proc.Signal(sig)
+	}
+	return syscall.Kill(-proc.Pid, sig.(syscall.Signal))
+}
+
+func killProcess(proc *os.Process, group bool) error {
+	return signalProcess(proc, group, syscall.SIGKILL)
+}
@@ -0,0 +1,141 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestForwardSignals(t *testing.T) {
+	tests := []struct {
+		name     string
+		script   string
+		group    bool
+		terminal bool
+		signals  []os.Signal
+		grace    time.Duration
+		exitCode int
+		killed   bool
+	}{
+		{name: "process exits on signal", script: `trap "exit 7" TERM; while :; do sleep 0.1; done`, exitCode: 7},
+		{name: "group reaches child of script", script: `trap : TERM; sh -c 'trap "exit 8" TERM; while :; do sleep 0.1; done'; exit $?`, group: true, exitCode: 8},
+		{name: "killed after grace period", script: `trap "" TERM; sleep 10`, group: true, grace: 200 * time.Millisecond, killed: true},
+		{
+			name:     "keyboard signals are not passed on twice",
+			script:   `trap "exit 9" INT; trap "exit 7" TERM; while :; do sleep 0.1; done`,
+			terminal: true,
+			signals:  []os.Signal{os.Interrupt, syscall.SIGQUIT, syscall.SIGTERM},
+			grace:    10 * time.Second,
+			exitCode: 7,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := exec.Command("sh", "-c", tt.script)
+			cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+			if err := cmd.Start(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			sigs := make(chan os.Signal, 1)
+			f := forwardSignals(cmd.Process, tt.group, tt.terminal, tt.grace, sigs)
+			defer f.stop()
+
+			// give the shell time to install its traps
+			time.Sleep(200 * time.Millisecond)
+			signals := tt.signals
+			if len(signals) == 0 {
+				signals = []os.Signal{syscall.SIGTERM}
+			}
+			for _, sig := range signals {
+				sigs <- sig
+			}
+
+			start := time.Now()
+			_ = cmd.Wait()
+			if time.Since(start) > 5*time.Second {
+				t.Errorf("process took %s to exit", time.Since(start))
+			}
+			status := cmd.ProcessState.Sys().(syscall.WaitStatus)
+			if tt.killed {
+				if !status.Signaled() || status.Signal() != syscall.SIGKILL {
+					t.Errorf("expected process to be killed, got %s", cmd.ProcessState)
+				}
+				return
+			}
+			if cmd.ProcessState.ExitCode() != tt.exitCode {
+				t.Errorf("exit code = %d, want %d", cmd.ProcessState.ExitCode(), tt.exitCode)
+			}
+		})
+	}
+}
+
+// TestRunProcessGroup runs the test binary again, with and without a
+// terminal, to check which process group the tool is started in. On a
+// terminal the tool must stay in the group of wtf to keep job control
+// working.
+func TestRunProcessGroup(t *testing.T) {
+	if out := os.Getenv("WTF_TEST_PGRP_OUT"); out != "" {
+		t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+		store, err := NewStore(t.TempDir())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		tf := &Terraform{tool: knownTools["terraform"], store: store}
+		rc := runContext{Version: mustVersions(t, "1.6.2")[0], WorkingDir: t.TempDir()}
+		w := wrapper{ScriptTemplate: "#!/bin/sh\nps -o pgid= -p $$ > " + shellquote(out)}
+		if _, err := tf.Run(rc, w); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := os.WriteFile(out+".wtf", []byte(strconv.Itoa(syscall.Getpgrp())), 0644); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return
+	}
+
+	for _, tool := range []string{"script", "ps"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s is not available", tool)
+		}
+	}
+	test := shellquote(os.Args[0], "-test.run=^TestRunProcessGroup$")
+
+	tests := []struct {
+		name      string
+		cmd       *exec.Cmd
+		sameGroup bool
+	}{
+		// script runs the command on a new pseudo terminal
+		{name: "terminal", cmd: exec.Command("script", "-qec", test, "/dev/null"), sameGroup: true},
+		{name: "no terminal", cmd: exec.Command("sh", "-c", test+" < /dev/null"), sameGroup: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := filepath.Join(t.TempDir(), "pgrp")
+			tt.cmd.Env = append(os.Environ(), "WTF_TEST_PGRP_OUT="+out)
+			if output, err := tt.cmd.CombinedOutput(); err != nil {
+				t.Fatalf("test binary failed: %v: %s", err, output)
+			}
+			tool, err := os.ReadFile(out)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			wtf, err := os.ReadFile(out + ".wtf")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			same := strings.TrimSpace(string(tool)) == strings.TrimSpace(string(wtf))
+			if same != tt.sameGroup {
+				t.Errorf("tool in group %s, wtf in group %s, want same group: %v", strings.TrimSpace(string(tool)), wtf, tt.sameGroup)
+			}
+		})
+	}
+}
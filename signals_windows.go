@@ -0,0 +1,28 @@
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// shutdownSignals are the signals wtf handles while the tool runs.
+var shutdownSignals = []os.Signal{os.Interrupt}
+
+// terminalSignals reach every process attached to the console.
+var terminalSignals = []os.Signal{os.Interrupt}
+
+// processGroup starts the tool like any other process; it shares the
+// console with wtf.
+func processGroup() (*syscall.SysProcAttr, bool) {
+	return nil, true
+}
+
+// signalProcess does nothing: Windows cannot send signals other than kill
+// and the console has delivered the interrupt to the tool already.
+func signalProcess(proc *os.Process, group bool, sig os.Signal) error {
+	return nil
+}
+
+func killProcess(proc *os.Process, group bool) error {
+	return proc.Kill()
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	ver "github.com/hashicorp/go-version"
+)
+
+// githubSource reads releases published as GitHub release assets. The tag
+// of a release is its version prefixed with `v`.
+type githubSource struct {
+	url   string
+	api   string
+	repo  string
+	cache *httpCache
+}
+
+// newGitHubSource creates a source for github.com, or for a GitHub
+// Enterprise server if url is set.
+func newGitHubSource(url, repo string, cache *httpCache) *githubSource {
+	if url == "" {
+		return &githubSource{url: "https://github.com", api: "https://api.github.com", repo: repo, cache: cache}
+	}
+	url = strings.TrimRight(url, "/")
+	return &githubSource{url: url, api: url + "/api/v3", repo: repo, cache: cache}
+}
+
+func (s *githubSource) String() string {
+	return fmt.Sprintf("%s/%s", s.url, s.repo)
+}
+
+func (s *githubSource) fileURL(v *ver.Version, filename string) string {
+	return fmt.Sprintf("%s/%s/releases/download/v%s/%s", s.url, s.repo, v.String(), filename)
+}
+
+func (s *githubSource) header() http.Header {
+	h := http.Header{}
+	h.Set("Accept", "application/vnd.github+json")
+	if token := os.Getenv("GITHUB_TOKEN"); token != "" {
+		h.Set("Authorization", "Bearer "+token)
+	}
+	return h
+}
+
+func (s *githubSource) List(t Tool) ([]Release, error) {
+	out := []Release{}
+
+	type release struct {
+		TagName     string    `json:"tag_name"`
+		Draft       bool      `json:"draft"`
+		Prerelease  bool      `json:"prerelease"`
+		PublishedAt time.Time `json:"published_at"`
+		Assets      []struct {
+			Name string `json:"name"`
+		} `json:"assets"`
+	}
+
+	for page := 1; ; page++ {
+		url := fmt.Sprintf("%s/repos/%s/releases?per_page=100&page=%d", s.api, s.repo, page)
+		body, err := s.cache.get(url, s.header())
+		if err != nil {
+			return out, err
+		}
+
+		releases := []release{}
+		if err := json.Unmarshal(body, &releases); err != nil {
+			return out, err
+		}
+		if len(releases) == 0 {
+			break
+		}
+
+		for _, r := range releases {
+			if r.Draft {
+				continue
+			}
+			version, err := ver.NewVersion(strings.TrimPrefix(r.TagName, "v"))
+			if err != nil {
+				continue
+			}
+			for _, asset := range r.Assets {
+				if asset.Name == t.assetName(version) {
+					release := newRelease(version)
+					release.Prerelease = release.Prerelease || r.Prerelease
+					release.Date = r.PublishedAt
+					out = append(out, release)
+					break
+				}
+			}
+		}
+	}
+
+	sortReleases(out)
+	return out, nil
+}
+
+func (s *githubSource) Checksum(t Tool, v *ver.Version, filename string) (string, error) {
+	return fetchExpectedChecksum(s.fileURL(v, t.checksumsName(v)), filename)
+}
+
+func (s *githubSource) Download(t Tool, v *ver.Version, filename string) (io.ReadCloser, int64, error) {
+	return httpDownload(s.fileURL(v, filename))
+}
+
+// ReleaseNotes returns the description of the GitHub release.
+func (s *githubSource) ReleaseNotes(t Tool, v *ver.Version) (string, error) {
+	url := fmt.Sprintf("%s/repos/%s/releases/tags/v%s", s.api, s.repo, v.String())
+	body, err := s.cache.get(url, s.header())
+	if err != nil {
+		return "", err
+	}
+	release := struct {
+		Body string `json:"body"`
+	}{}
+	if err := json.Unmarshal(body, &release); err != nil {
+		return "", err
+	}
+	return release.Body, nil
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+
+	ver "github.com/hashicorp/go-version"
+)
+
+// httpSource reads releases from a plain HTTP file server mirroring the
+// layout of releases.hashicorp.com: <url>/<tool>/<version>/<file>. Versions
+// are listed by parsing the directory index served at <url>/<tool>/.
+type httpSource struct {
+	url   string
+	cache *httpCache
+}
+
+var hrefPattern = regexp.MustCompile(`href="([^"]+)"`)
+
+func (s *httpSource) String() string {
+	return s.url
+}
+
+func (s *httpSource) fileURL(t Tool, v *ver.Version, filename string) string {
+	return fmt.Sprintf("%s/%s/%s/%s", s.url, t.Name, v.String(), filename)
+}
+
+func (s *httpSource) List(t Tool) ([]Release, error) {
+	out := []Release{}
+
+	url := fmt.Sprintf("%s/%s/", s.url, t.Name)
+	body, err := s.cache.get(url, nil)
+	if err != nil {
+		return out, err
+	}
+
+	seen := map[string]bool{}
+	for _, m := range hrefPattern.FindAllSubmatch(body, -1) {
+		name := path.Base(strings.TrimSuffix(string(m[1]), "/"))
+		name = strings.TrimPrefix(name, t.Name+"_")
+		v, err := ver.NewVersion(name)
+		if err != nil || seen[v.String()] {
+			continue
+		}
+		seen[v.String()] = true
+		out = append(out, newRelease(v))
+	}
+
+	sortReleases(out)
+	return out, nil
+}
+
+func (s *httpSource) Checksum(t Tool, v *ver.Version, filename string) (string, error) {
+	return fetchExpectedChecksum(s.fileURL(t, v, t.checksumsName(v)), filename)
+}
+
+func (s *httpSource) Download(t Tool, v *ver.Version, filename string) (io.ReadCloser, int64, error) {
+	return httpDownload(s.fileURL(t, v, filename))
+}
+
+// hashicorpSource reads releases from releases.hashicorp.com (or a mirror
+// of it), using the index.json of a product to list its versions. For
+// releases.hashicorp.com itself, publication dates and the state of the
+// releases are read from the releases API.
+type hashicorpSource struct {
+	httpSource
+	api string
+}
+
+const (
+	hashicorpReleasesURL = "https://releases.hashicorp.com"
+	hashicorpAPIURL      = "https://api.releases.hashicorp.com"
+)
+
+// hashicorpAPIPageSize is the maximum page size of the releases API.
+var hashicorpAPIPageSize = 20
+
+func newHashiCorpSource(url string, cache *httpCache) *hashicorpSource {
+	if url == "" {
+		return &hashicorpSource{httpSource: httpSource{url: hashicorpReleasesURL, cache: cache}, api: hashicorpAPIURL}
+	}
+	return &hashicorpSource{httpSource: httpSource{url: strings.TrimRight(url, "/"), cache: cache}}
+}
+
+func (s *hashicorpSource) List(t Tool) ([]Release, error) {
+	out := []Release{}
+
+	type releaseInfo struct {
+		Versions map[string]struct {
+			Builds []struct {
+				Os   string `json:"os"`
+				Arch string `json:"arch"`
+			} `json:"builds"`
+		} `json:"versions"`
+	}
+
+	url := fmt.Sprintf("%s/%s/index.json", s.url, t.Name)
+	body, err := s.cache.get(url, nil)
+	if err != nil {
+		return out, err
+	}
+
+	releases := releaseInfo{}
+	err = json.Unmarshal(body, &releases)
+	if err != nil {
+		return out, err
+	}
+
+	details := map[string]Release{}
+	if s.api != "" {
+		// the index is usable without the details, so errors are not fatal
+		details, err = s.details(t)
+		if err != nil {
+			logger.Warn("could not read release details", "error", err.Error())
+		}
+	}
+
+	for vs, spec := range releases.Versions {
+		version, err := ver.NewVersion(vs)
+		if err != nil {
+			continue
+		}
+		for _, build := range spec.Builds {
+			if build.Arch == runtime.GOARCH && build.Os == runtime.GOOS {
+				r, ok := details[version.String()]
+				if !ok {
+					r = newRelease(version)
+				}
+				out = append(out, r)
+				break
+			}
+		}
+	}
+
+	sortReleases(out)
+	return out, nil
+}
+
+// details reads the publication date and state of all releases of a tool
+// from the paginated releases API.
+func (s *hashicorpSource) details(t Tool) (map[string]Release, error) {
+	type apiRelease struct {
+		Version      string `json:"version"`
+		IsPrerelease bool   `json:"is_prerelease"`
+		Created      string `json:"timestamp_created"`
+		Status       struct {
+			State string `json:"state"`
+		} `json:"status"`
+	}
+
+	out := map[string]Release{}
+	after := ""
+	for {
+		url := fmt.Sprintf("%s/v1/releases/%s?limit=%d", s.api, t.Name, hashicorpAPIPageSize)
+		if after != "" {
+			url = fmt.Sprintf("%s&after=%s", url, after)
+		}
+		body, err := s.cache.get(url, nil)
+		if err != nil {
+			return out, err
+		}
+
+		page := []apiRelease{}
+		if err := json.Unmarshal(body, &page); err != nil {
+			return out, err
+		}
+
+		for _, r := range page {
+			v, err := ver.NewVersion(r.Version)
+			if err != nil {
+				continue
+			}
+			release := newRelease(v)
+			release.Prerelease = release.Prerelease || r.IsPrerelease
+			release.Withdrawn = r.Status.State == "withdrawn"
+			if created, err := time.Parse(time.RFC3339, r.Created); err == nil {
+				release.Date = created
+			}
+			out[v.String()] = release
+		}
+
+		if len(page) < hashicorpAPIPageSize {
+			return out, nil
+		}
+		after = page[len(page)-1].Created
+	}
+}
+
+// ReleaseNotes looks up the changelog of a release using the releases API
+// and returns the section of the version. Mirrors do not provide release
+// notes.
+func (s *hashicorpSource) ReleaseNotes(t Tool, v *ver.Version) (string, error) {
+	if s.api == "" {
+		return "", errNoReleaseNotes
+	}
+	body, err := s.cache.get(fmt.Sprintf("%s/v1/releases/%s/%s", s.api, t.Name, v.String()), nil)
+	if err != nil {
+		return "", err
+	}
+	release := struct {
+		Changelog string `json:"url_changelog"`
+	}{}
+	if err := json.Unmarshal(body, &release); err != nil {
+		return "", err
+	}
+	if release.Changelog == "" {
+		return "", errNoReleaseNotes
+	}
+
+	changelog, err := s.cache.get(githubRawURL(release.Changelog), nil)
+	if err != nil {
+		return "", err
+	}
+	notes, found := changelogSection(string(changelog), v)
+	if !found {
+		return "", fmt.Errorf("%s does not mention %s", release.Changelog, v.String())
+	}
+	return notes, nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+
+	ver "github.com/hashicorp/go-version"
+)
+
+// localSource reads releases from a directory using the same layout as the
+// http source: <path>/<tool>/<version>/<file>. Like every other source it
+// requires the SHA256SUMS file of a release to be present.
+type localSource struct {
+	path string
+}
+
+func (s *localSource) String() string {
+	return s.path
+}
+
+func (s *localSource) filePath(t Tool, v *ver.Version, filename string) string {
+	return filepath.Join(s.path, t.Name, v.String(), filename)
+}
+
+func (s *localSource) List(t Tool) ([]Release, error) {
+	out := []Release{}
+	entries, err := os.ReadDir(filepath.Join(s.path, t.Name))
+	if errors.Is(err, os.ErrNotExist) {
+		return out, nil
+	} else if err != nil {
+		return out, err
+	}
+
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		v, err := ver.NewVersion(e.Name())
+		if err != nil {
+			continue
+		}
+		if _, err := os.Stat(s.filePath(t, v, t.assetName(v))); err != nil {
+			continue
+		}
+		out = append(out, newRelease(v))
+	}
+
+	sortReleases(out)
+	return out, nil
+}
+
+func (s *localSource) Checksum(t Tool, v *ver.Version, filename string) (string, error) {
+	sums, err := os.ReadFile(s.filePath(t, v, t.checksumsName(v)))
+	if err != nil {
+		return "", err
+	}
+	return findChecksum(sums, filename)
+}
+
+func (s *localSource) Download(t Tool, v *ver.Version, filename string) (io.ReadCloser, int64, error) {
+	f, err := os.Open(s.filePath(t, v, filename))
+	if err != nil {
+		return nil, 0, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, 0, err
+	}
+	return f, info.Size(), nil
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	ver "github.com/hashicorp/go-version"
+)
+
+// ReleaseSource provides the releases of a tool. Every release consists of
+// files such as the archive for a platform and a SHA256SUMS file.
+type ReleaseSource interface {
+	// String describes the source in messages.
+	String() string
+	// List returns the releases available for the current platform sorted
+	// by version.
+	List(t Tool) ([]Release, error)
+	// Checksum returns the expected SHA256 checksum of a release file.
+	Checksum(t Tool, v *ver.Version, filename string) (string, error)
+	// Download opens a release file and returns its size, or -1 if the
+	// size is unknown.
+	Download(t Tool, v *ver.Version, filename string) (io.ReadCloser, int64, error)
+}
+
+// Release is a version of a tool along with the metadata the release source
+// knows about it.
+type Release struct {
+	Version *ver.Version
+	// Date is the publication date, zero if unknown.
+	Date       time.Time
+	Prerelease bool
+	Withdrawn  bool
+}
+
+func newRelease(v *ver.Version) Release {
+	return Release{Version: v, Prerelease: v.Prerelease() != ""}
+}
+
+func sortReleases(releases []Release) {
+	sort.Slice(releases, func(i, j int) bool {
+		return releases[i].Version.LessThan(releases[j].Version)
+	})
+}
+
+func releaseVersions(releases []Release) ver.Collection {
+	out := ver.Collection{}
+	for _, r := range releases {
+		out = append(out, r.Version)
+	}
+	return out
+}
+
+const (
+	sourceUpstream  = "upstream"
+	sourceHashiCorp = "hashicorp"
+	sourceGitHub    = "github"
+	sourceHTTP      = "http"
+	sourceLocal     = "local"
+)
+
+// sourceConfig configures a release source. Type `upstream` refers to the
+// source a tool is published with (see Tool.Releases).
+type sourceConfig struct {
+	Type string `yaml:"type"`
+	// URL of the hashicorp, github (enterprise) or http source.
+	URL string `yaml:"url"`
+	// Path of the local source.
+	Path string `yaml:"path"`
+	// Repo of the github source, defaults to the repo of the tool.
+	Repo string `yaml:"repo"`
+}
+
+var defaultSources = []sourceConfig{{Type: sourceUpstream}}
+
+// errChecksumMismatch is returned if a downloaded file does not match its
+// checksum. Other sources are not tried in this case.
+var errChecksumMismatch = errors.New("checksum mismatch")
+
+// releaseNotesSource is implemented by release sources which know where the
+// release notes of a version are published.
+type releaseNotesSource interface {
+	// ReleaseNotes returns the release notes of a version as markdown.
+	ReleaseNotes(t Tool, v *ver.Version) (string, error)
+}
+
+// errNoReleaseNotes is returned by sources which cannot provide release
+// notes for a tool.
+var errNoReleaseNotes = errors.New("release notes not available")
+
+// newReleaseSource creates a source. Release listings are cached in cache,
+// which may be nil.
+func newReleaseSource(c sourceConfig, t Tool, cache *httpCache) (ReleaseSource, error) {
+	typ := c.Type
+	if typ == sourceUpstream || typ == "" {
+		typ = t.Releases
+	}
+
+	switch typ {
+	case sourceHashiCorp:
+		return newHashiCorpSource(c.URL, cache), nil
+	case sourceGitHub:
+		repo := c.Repo
+		if repo == "" {
+			repo = t.Repo
+		}
+		if repo == "" {
+			return nil, fmt.Errorf("github source for %s requires a repo", t.Name)
+		}
+		return newGitHubSource(c.URL, repo, cache), nil
+	case sourceHTTP:
+		if c.URL == "" {
+			return nil, fmt.Errorf("http source for %s requires a url", t.Name)
+		}
+		return &httpSource{url: strings.TrimRight(c.URL, "/"), cache: cache}, nil
+	case sourceLocal:
+		if c.Path == "" {
+			return nil, fmt.Errorf("local source for %s requires a path", t.Name)
+		}
+		path, err := expandPath(c.Path)
+		if err != nil {
+			return nil, err
+		}
+		return &localSource{path: path}, nil
+	default:
+		return nil, fmt.Errorf("unknown source type '%s'", c.Type)
+	}
+}
+
+func newReleaseSources(t Tool, cache *httpCache) ([]ReleaseSource, error) {
+	configs := t.Sources
+	if len(configs) == 0 {
+		configs = defaultSources
+	}
+	out := []ReleaseSource{}
+	for _, c := range configs {
+		s, err := newReleaseSource(c, t, cache)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, nil
+}
+
+// assetName returns the name of the release file for the current platform,
+// e.g. terraform_1.6.2_linux_amd64.zip.
+func (t Tool) assetName(v *ver.Version) string {
+	if t.Asset == "" {
+		return t.expand(defaultAsset, v)
+	}
+	return t.expand(t.Asset, v)
+}
+
+// checksumsName returns the name of the SHA256SUMS file of a release.
+func (t Tool) checksumsName(v *ver.Version) string {
+	if t.Checksums == "" {
+		return t.expand(defaultChecksums, v)
+	}
+	return t.expand(t.Checksums, v)
+}
+
+func (t Tool) expand(pattern string, v *ver.Version) string {
+	exe := ""
+	if runtime.GOOS == "windows" {
+		exe = ".exe"
+	}
+	return strings.NewReplacer(
+		"{name}", t.Name,
+		"{version}", v.String(),
+		"{os}", runtime.GOOS,
+		"{arch}", runtime.GOARCH,
+		"{exe}", exe,
+	).Replace(pattern)
+}
+
+// httpGet requests url and fails on any non 2xx status.
+func httpGet(url string, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("could not download %s: %s", url, err.Error())
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("could not download %s: %s", url, resp.Status)
+	}
+	return resp, nil
+}
+
+func httpDownload(url string) (io.ReadCloser, int64, error) {
+	resp, err := httpGet(url, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	return resp.Body, resp.ContentLength, nil
+}
+
+// fetchExpectedChecksum downloads the SHA256SUMS file at url and returns the
+// expected checksum for the given filename.
+func fetchExpectedChecksum(url string, filename string) (string, error) {
+	resp, err := httpGet(url, nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("could not read checksums: %s", err.Error())
+	}
+	return findChecksum(body, filename)
+}
+
+// findChecksum looks up filename in the content of a SHA256SUMS file.
+func findChecksum(sums []byte, filename string) (string, error) {
+	// SHA256SUMS format: "<hash>  <filename>\n"
+	for _, line := range strings.Split(string(sums), "\n") {
+		parts := strings.Fields(line)
+		if len(parts) != 2 {
+			continue
+		}
+		if strings.TrimPrefix(parts[1], "*") == filename {
+			return parts[0], nil
+		}
+	}
+
+	return "", fmt.Errorf("checksum not found for %s", filename)
+}
@@ -0,0 +1,474 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// helper to create a release archive containing a single binary
+func mustZip(t *testing.T, name, content string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.Create(name)
+	if err != nil {
+		t.Fatalf("could not create zip entry: %v", err)
+	}
+	if _, err := w.Write([]byte(content)); err != nil {
+		t.Fatalf("could not write zip entry: %v", err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("could not close zip: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func sha256Hex(data []byte) string {
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
+
+// helper to create a local source directory with a single release
+func mustLocalRelease(t *testing.T, tool Tool, version string, archive []byte, sum string) string {
+	t.Helper()
+	root := t.TempDir()
+	v := mustVersions(t, version)[0]
+	dir := filepath.Join(root, tool.Name, version)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("could not create dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, tool.assetName(v)), archive, 0644); err != nil {
+		t.Fatalf("could not write archive: %v", err)
+	}
+	sums := fmt.Sprintf("%s  %s\n", sum, tool.assetName(v))
+	if err := os.WriteFile(filepath.Join(dir, tool.checksumsName(v)), []byte(sums), 0644); err != nil {
+		t.Fatalf("could not write checksums: %v", err)
+	}
+	return root
+}
+
+func TestFindChecksum(t *testing.T) {
+	sums := []byte("abc  terraform_1.6.2_linux_amd64.zip\ndef *terraform_1.6.2_darwin_arm64.zip\n\nbroken line here\n")
+
+	tests := []struct {
+		filename    string
+		expected    string
+		expectError bool
+	}{
+		{filename: "terraform_1.6.2_linux_amd64.zip", expected: "abc"},
+		{filename: "terraform_1.6.2_darwin_arm64.zip", expected: "def"},
+		{filename: "terraform_1.6.2_windows_amd64.zip", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filename, func(t *testing.T) {
+			result, err := findChecksum(sums, tt.filename)
+			if tt.expectError {
+				if err == nil {
+					t.Error("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result != tt.expected {
+				t.Errorf("findChecksum() = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestAssetNames(t *testing.T) {
+	v := mustVersions(t, "0.55.1")[0]
+	exe := ""
+	if runtime.GOOS == "windows" {
+		exe = ".exe"
+	}
+
+	tests := []struct {
+		tool              Tool
+		expectedAsset     string
+		expectedChecksums string
+	}{
+		{
+			tool:              knownTools["terraform"],
+			expectedAsset:     fmt.Sprintf("terraform_0.55.1_%s_%s.zip", runtime.GOOS, runtime.GOARCH),
+			expectedChecksums: "terraform_0.55.1_SHA256SUMS",
+		},
+		{
+			tool:              knownTools["terragrunt"],
+			expectedAsset:     fmt.Sprintf("terragrunt_%s_%s%s", runtime.GOOS, runtime.GOARCH, exe),
+			expectedChecksums: "SHA256SUMS",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.tool.Name, func(t *testing.T) {
+			if result := tt.tool.assetName(v); result != tt.expectedAsset {
+				t.Errorf("assetName() = %q, want %q", result, tt.expectedAsset)
+			}
+			if result := tt.tool.checksumsName(v); result != tt.expectedChecksums {
+				t.Errorf("checksumsName() = %q, want %q", result, tt.expectedChecksums)
+			}
+		})
+	}
+}
+
+func TestNewReleaseSource(t *testing.T) {
+	terraform := knownTools["terraform"]
+	tofu := knownTools["tofu"]
+
+	tests := []struct {
+		name        string
+		config      sourceConfig
+		tool        Tool
+		expected    string
+		expectError bool
+	}{
+		{name: "upstream of hashicorp tool", config: sourceConfig{Type: sourceUpstream}, tool: terraform, expected: "https://releases.hashicorp.com"},
+		{name: "upstream of github tool", config: sourceConfig{Type: sourceUpstream}, tool: tofu, expected: "https://github.com/opentofu/opentofu"},
+		{name: "github enterprise", config: sourceConfig{Type: sourceGitHub, URL: "https://ghe.example.com/", Repo: "infra/terraform"}, tool: terraform, expected: "https://ghe.example.com/infra/terraform"},
+		{name: "http", config: sourceConfig{Type: sourceHTTP, URL: "https://mirror.example.com/"}, tool: terraform, expected: "https://mirror.example.com"},
+		{name: "local", config: sourceConfig{Type: sourceLocal, Path: "/srv/mirror"}, tool: terraform, expected: "/srv/mirror"},
+		{name: "github without repo", config: sourceConfig{Type: sourceGitHub}, tool: terraform, expectError: true},
+		{name: "http without url", config: sourceConfig{Type: sourceHTTP}, tool: terraform, expectError: true},
+		{name: "local without path", config: sourceConfig{Type: sourceLocal}, tool: terraform, expectError: true},
+		{name: "unknown type", config: sourceConfig{Type: "ftp"}, tool: terraform, expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := newReleaseSource(tt.config, tt.tool, nil)
+			if tt.expectError {
+				if err == nil {
+					t.Error("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if s.String() != tt.expected {
+				t.Errorf("String() = %q, want %q", s.String(), tt.expected)
+			}
+		})
+	}
+}
+
+func TestSourceList(t *testing.T) {
+	platform := fmt.Sprintf(`{"os": %q, "arch": %q}`, runtime.GOOS, runtime.GOARCH)
+	tool := knownTools["terraform"]
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/hashicorp/terraform/index.json", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"versions": {
+			"1.5.7": {"builds": [%s]},
+			"1.6.0": {"builds": [{"os": "plan9", "arch": "mips"}]},
+			"1.6.2": {"builds": [%s]}
+		}}`, platform, platform)
+	})
+	mux.HandleFunc("/mirror/terraform/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<ul>
+			<li><a href="../">../</a></li>
+			<li><a href="/terraform/1.6.2/">terraform_1.6.2</a></li>
+			<li><a href="1.5.7/">1.5.7/</a></li>
+			<li><a href="1.5.7/">1.5.7/</a></li>
+		</ul>`)
+	})
+	mux.HandleFunc("/api/v3/repos/opentofu/opentofu/releases", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") != "1" {
+			fmt.Fprint(w, `[]`)
+			return
+		}
+		fmt.Fprintf(w, `[
+			{"tag_name": "v1.6.2", "assets": [{"name": "terraform_1.6.2_%[1]s_%[2]s.zip"}]},
+			{"tag_name": "v1.7.0", "draft": true, "assets": [{"name": "terraform_1.7.0_%[1]s_%[2]s.zip"}]},
+			{"tag_name": "v1.6.1", "assets": [{"name": "terraform_1.6.1_plan9_mips.zip"}]},
+			{"tag_name": "nightly", "assets": []}
+		]`, runtime.GOOS, runtime.GOARCH)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		source   ReleaseSource
+		expected string
+	}{
+		{
+			name:     "hashicorp index",
+			source:   &hashicorpSource{httpSource: httpSource{url: server.URL + "/hashicorp"}},
+			expected: "1.5.7\n1.6.2",
+		},
+		{
+			name:     "http directory index",
+			source:   &httpSource{url: server.URL + "/mirror"},
+			expected: "1.5.7\n1.6.2",
+		},
+		{
+			name:     "github releases",
+			source:   newGitHubSource(server.URL, "opentofu/opentofu", nil),
+			expected: "1.6.2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			releases, err := tt.source.List(tool)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			result := (&Terraform{versions: releaseVersions(releases)}).String()
+			if result != tt.expected {
+				t.Errorf("List() = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSourceListMetadata(t *testing.T) {
+	platform := fmt.Sprintf(`{"os": %q, "arch": %q}`, runtime.GOOS, runtime.GOARCH)
+	tool := knownTools["terraform"]
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/terraform/index.json", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"versions": {
+			"1.5.6": {"builds": [%[1]s]},
+			"1.5.7": {"builds": [%[1]s]},
+			"1.6.0-rc1": {"builds": [%[1]s]}
+		}}`, platform)
+	})
+	mux.HandleFunc("/v1/releases/terraform", func(w http.ResponseWriter, r *http.Request) {
+		// page size is reduced to 2 below to exercise pagination
+		switch r.URL.Query().Get("after") {
+		case "":
+			fmt.Fprint(w, `[
+				{"version": "1.6.0-rc1", "is_prerelease": true, "timestamp_created": "2023-09-20T10:00:00.000Z", "status": {"state": "supported"}},
+				{"version": "1.5.7", "timestamp_created": "2023-09-07T10:00:00.000Z", "status": {"state": "supported"}}
+			]`)
+		case "2023-09-07T10:00:00.000Z":
+			fmt.Fprint(w, `[
+				{"version": "1.5.6", "timestamp_created": "2023-08-23T10:00:00.000Z", "status": {"state": "withdrawn"}}
+			]`)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	mux.HandleFunc("/api/v3/repos/opentofu/opentofu/releases", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") != "1" {
+			fmt.Fprint(w, `[]`)
+			return
+		}
+		fmt.Fprintf(w, `[
+			{"tag_name": "v1.7.0-beta1", "prerelease": true, "published_at": "2024-03-01T10:00:00Z", "assets": [{"name": "terraform_1.7.0-beta1_%[1]s_%[2]s.zip"}]},
+			{"tag_name": "v1.6.2", "published_at": "2024-02-01T10:00:00Z", "assets": [{"name": "terraform_1.6.2_%[1]s_%[2]s.zip"}]}
+		]`, runtime.GOOS, runtime.GOARCH)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	hashicorp := &hashicorpSource{httpSource: httpSource{url: server.URL}, api: server.URL}
+	github := newGitHubSource(server.URL, "opentofu/opentofu", nil)
+
+	tests := []struct {
+		name     string
+		source   ReleaseSource
+		expected []string
+	}{
+		{
+			name:   "hashicorp releases api",
+			source: hashicorp,
+			expected: []string{
+				"1.5.6 2023-08-23 prerelease=false withdrawn=true",
+				"1.5.7 2023-09-07 prerelease=false withdrawn=false",
+				"1.6.0-rc1 2023-09-20 prerelease=true withdrawn=false",
+			},
+		},
+		{
+			name:   "github releases",
+			source: github,
+			expected: []string{
+				"1.6.2 2024-02-01 prerelease=false withdrawn=false",
+				"1.7.0-beta1 2024-03-01 prerelease=true withdrawn=false",
+			},
+		},
+	}
+
+	pageSize := hashicorpAPIPageSize
+	defer func() { hashicorpAPIPageSize = pageSize }()
+	hashicorpAPIPageSize = 2
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			releases, err := tt.source.List(tool)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			result := []string{}
+			for _, r := range releases {
+				result = append(result, fmt.Sprintf("%s %s prerelease=%v withdrawn=%v", r.Version, r.Date.Format("2006-01-02"), r.Prerelease, r.Withdrawn))
+			}
+			if strings.Join(result, "\n") != strings.Join(tt.expected, "\n") {
+				t.Errorf("List() =\n%s\nwant\n%s", strings.Join(result, "\n"), strings.Join(tt.expected, "\n"))
+			}
+		})
+	}
+}
+
+func TestLocalSource(t *testing.T) {
+	tool := knownTools["terraform"]
+	archive := mustZip(t, binaryName("terraform"), "binary")
+	s := &localSource{path: mustLocalRelease(t, tool, "1.6.2", archive, sha256Hex(archive))}
+	v := mustVersions(t, "1.6.2")[0]
+
+	releases, err := s.List(tool)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(releases) != 1 || !releases[0].Version.Equal(v) {
+		t.Errorf("List() = %v, want [1.6.2]", releases)
+	}
+
+	sum, err := s.Checksum(tool, v, tool.assetName(v))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if sum != sha256Hex(archive) {
+		t.Errorf("Checksum() = %q, want %q", sum, sha256Hex(archive))
+	}
+
+	r, size, err := s.Download(tool, v, tool.assetName(v))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if size != int64(len(archive)) || !bytes.Equal(data, archive) {
+		t.Errorf("Download() returned %d bytes (size %d), want %d", len(data), size, len(archive))
+	}
+
+	other, err := s.List(knownTools["packer"])
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(other) != 0 {
+		t.Errorf("expected no versions of other tool, got %v", other)
+	}
+}
+
+func TestDownloadVersionRawBinary(t *testing.T) {
+	tool := knownTools["terragrunt"]
+	binary := []byte("terragrunt binary")
+	v := mustVersions(t, "0.55.1")[0]
+
+	store, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tf := &Terraform{
+		tool:    tool,
+		store:   store,
+		sources: []ReleaseSource{&localSource{path: mustLocalRelease(t, tool, "0.55.1", binary, sha256Hex(binary))}},
+	}
+
+	filename, err := tf.DownloadVersion(v)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	content, err := os.ReadFile(filename)
+	if err != nil || !bytes.Equal(content, binary) {
+		t.Errorf("installed binary has content %q (%v)", content, err)
+	}
+}
+
+func TestDownloadVersionFallback(t *testing.T) {
+	tool := knownTools["terraform"]
+	archive := mustZip(t, binaryName("terraform"), "binary")
+	v := mustVersions(t, "1.6.2")[0]
+
+	tests := []struct {
+		name          string
+		sources       func(t *testing.T) []ReleaseSource
+		expectError   bool
+		errorContains string
+	}{
+		{
+			name: "falls back to next source",
+			sources: func(t *testing.T) []ReleaseSource {
+				return []ReleaseSource{
+					&localSource{path: t.TempDir()},
+					&localSource{path: mustLocalRelease(t, tool, "1.6.2", archive, sha256Hex(archive))},
+				}
+			},
+		},
+		{
+			name: "reports all sources if none provides the version",
+			sources: func(t *testing.T) []ReleaseSource {
+				return []ReleaseSource{
+					&localSource{path: t.TempDir()},
+					&localSource{path: t.TempDir()},
+				}
+			},
+			expectError:   true,
+			errorContains: "no source provides terraform 1.6.2",
+		},
+		{
+			name: "checksum mismatch does not fall back",
+			sources: func(t *testing.T) []ReleaseSource {
+				return []ReleaseSource{
+					&localSource{path: mustLocalRelease(t, tool, "1.6.2", archive, sha256Hex([]byte("other")))},
+					&localSource{path: mustLocalRelease(t, tool, "1.6.2", archive, sha256Hex(archive))},
+				}
+			},
+			expectError:   true,
+			errorContains: "checksum mismatch",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, err := NewStore(t.TempDir())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			tf := &Terraform{tool: tool, store: store, sources: tt.sources(t)}
+
+			filename, err := tf.DownloadVersion(v)
+			if tt.expectError {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				if !strings.Contains(err.Error(), tt.errorContains) {
+					t.Errorf("error %q does not contain %q", err.Error(), tt.errorContains)
+				}
+				if tt.errorContains == "checksum mismatch" && !errors.Is(err, errChecksumMismatch) {
+					t.Error("expected errChecksumMismatch")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			content, err := os.ReadFile(filename)
+			if err != nil || string(content) != "binary" {
+				t.Errorf("installed binary has content %q (%v)", content, err)
+			}
+		})
+	}
+}
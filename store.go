@@ -0,0 +1,388 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	ver "github.com/hashicorp/go-version"
+)
+
+// storeLayoutVersion is the version of the on-disk layout written by this
+// build. Stores without a layout marker are treated as the original flat
+// layout (one binary per version directly in the store root) and are
+// migrated on open.
+//
+// Layout version 2:
+//
+//	<root>/layout.json
+//	<root>/<tool>/<version>/<os>_<arch>/<binary>
+//	<root>/<tool>/<version>/<os>_<arch>/meta.json
+const storeLayoutVersion = 2
+
+const (
+	storeLayoutFile = "layout.json"
+	storeLockFile   = ".lock"
+	storeMetaFile   = "meta.json"
+)
+
+// storeLockTimeout is how long to wait for another wtf process to finish
+// migrating the store before giving up.
+var storeLockTimeout = 30 * time.Second
+
+type storeLayout struct {
+	Version int `json:"version"`
+}
+
+// BinaryMeta is stored next to every installed binary.
+type BinaryMeta struct {
+	Tool        string    `json:"tool"`
+	Version     string    `json:"version"`
+	OS          string    `json:"os"`
+	Arch        string    `json:"arch"`
+	SHA256      string    `json:"sha256"`
+	Source      string    `json:"source,omitempty"`
+	InstalledAt time.Time `json:"installed_at"`
+}
+
+// Store manages the binaries installed by wtf.
+type Store struct {
+	root string
+}
+
+func NewStore(path string) (*Store, error) {
+	root, err := expandPath(path)
+	if err != nil {
+		return nil, err
+	}
+	root = strings.TrimRight(root, "/")
+	if err := createDir(root); err != nil {
+		return nil, err
+	}
+
+	s := &Store{root: root}
+	if err := s.migrate(); err != nil {
+		return nil, fmt.Errorf("could not migrate store '%s': %w", root, err)
+	}
+	return s, nil
+}
+
+func platformDir() string {
+	return fmt.Sprintf("%s_%s", runtime.GOOS, runtime.GOARCH)
+}
+
+func binaryName(name string) string {
+	if runtime.GOOS == "windows" {
+		return name + ".exe"
+	}
+	return name
+}
+
+// ToolDir returns the directory holding all versions of a tool.
+func (s *Store) ToolDir(tool string) string {
+	return filepath.Join(s.root, tool)
+}
+
+func (s *Store) versionDir(tool string, v *ver.Version) string {
+	return filepath.Join(s.ToolDir(tool), v.String(), platformDir())
+}
+
+// BinaryPath returns the path of the binary of a tool version for the
+// current platform. The file does not necessarily exist.
+func (s *Store) BinaryPath(tool string, v *ver.Version) string {
+	return filepath.Join(s.versionDir(tool, v), binaryName(tool))
+}
+
+// Versions lists the versions of a tool installed for the current platform.
+func (s *Store) Versions(tool string) (ver.Collection, error) {
+	out := ver.Collection{}
+	entries, err := os.ReadDir(s.ToolDir(tool))
+	if errors.Is(err, os.ErrNotExist) {
+		return out, nil
+	} else if err != nil {
+		return out, err
+	}
+
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		v, err := ver.NewVersion(e.Name())
+		if err != nil {
+			continue
+		}
+		if _, err := os.Stat(s.BinaryPath(tool, v)); err != nil {
+			continue
+		}
+		out = append(out, v)
+	}
+	return out, nil
+}
+
+// Meta reads the metadata of an installed binary.
+func (s *Store) Meta(tool string, v *ver.Version) (BinaryMeta, error) {
+	m := BinaryMeta{}
+	data, err := os.ReadFile(filepath.Join(s.versionDir(tool, v), storeMetaFile))
+	if err != nil {
+		return m, err
+	}
+	err = json.Unmarshal(data, &m)
+	return m, err
+}
+
+// Install writes a binary into the store. The binary is written to a
+// temporary file first and renamed into place so that concurrent readers
+// never see a partially written file.
+func (s *Store) Install(tool string, v *ver.Version, data []byte, source string) (string, error) {
+	dir := s.versionDir(tool, v)
+	if err := createDir(dir); err != nil {
+		return "", err
+	}
+
+	tmp, err := os.CreateTemp(dir, ".install.*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Chmod(tmp.Name(), 0700); err != nil {
+		return "", err
+	}
+
+	filename := s.BinaryPath(tool, v)
+	if err := os.Rename(tmp.Name(), filename); err != nil {
+		return "", err
+	}
+
+	hash := sha256.Sum256(data)
+	meta := BinaryMeta{
+		Tool:        tool,
+		Version:     v.String(),
+		OS:          runtime.GOOS,
+		Arch:        runtime.GOARCH,
+		SHA256:      hex.EncodeToString(hash[:]),
+		Source:      source,
+		InstalledAt: time.Now().UTC(),
+	}
+	if err := writeJSONFile(filepath.Join(dir, storeMetaFile), meta); err != nil {
+		return "", err
+	}
+
+	return filename, nil
+}
+
+func (s *Store) layoutVersion() (int, error) {
+	data, err := os.ReadFile(filepath.Join(s.root, storeLayoutFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return 1, nil
+	} else if err != nil {
+		return 0, err
+	}
+	l := storeLayout{}
+	if err := json.Unmarshal(data, &l); err != nil {
+		return 0, fmt.Errorf("invalid layout marker: %w", err)
+	}
+	return l.Version, nil
+}
+
+// migrate upgrades the store to the current layout. It holds a lock file
+// while doing so, and every step can be repeated safely, so a migration
+// that was interrupted is simply completed on the next run.
+func (s *Store) migrate() error {
+	version, err := s.layoutVersion()
+	if err != nil {
+		return err
+	}
+	if version == storeLayoutVersion {
+		return nil
+	}
+	if version > storeLayoutVersion {
+		return fmt.Errorf("store layout version %d is newer than supported version %d, please upgrade wtf", version, storeLayoutVersion)
+	}
+
+	unlock, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// another process might have migrated the store while we were waiting
+	version, err = s.layoutVersion()
+	if err != nil {
+		return err
+	}
+
+	if version == 1 {
+		if err := s.migrateFlat(); err != nil {
+			return err
+		}
+	}
+
+	return writeJSONFile(filepath.Join(s.root, storeLayoutFile), storeLayout{Version: storeLayoutVersion})
+}
+
+// migrateFlat moves binaries stored as <root>/<version> into the terraform
+// tool directory. Entries that do not look like versions are left alone.
+func (s *Store) migrateFlat() error {
+	entries, err := os.ReadDir(s.root)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		if !e.Type().IsRegular() {
+			continue
+		}
+		v, err := ver.NewVersion(e.Name())
+		if err != nil {
+			continue
+		}
+
+		src := filepath.Join(s.root, e.Name())
+		dest := s.BinaryPath("terraform", v)
+
+		if _, err := os.Stat(dest); err == nil {
+			// already migrated by an interrupted run, the flat copy is redundant
+			if err := os.Remove(src); err != nil {
+				return err
+			}
+			continue
+		}
+
+		sum, err := fileSHA256(src)
+		if err != nil {
+			return err
+		}
+		if err := createDir(filepath.Dir(dest)); err != nil {
+			return err
+		}
+		meta := BinaryMeta{
+			Tool:        "terraform",
+			Version:     v.String(),
+			OS:          runtime.GOOS,
+			Arch:        runtime.GOARCH,
+			SHA256:      sum,
+			Source:      "migrated",
+			InstalledAt: time.Now().UTC(),
+		}
+		if err := writeJSONFile(filepath.Join(filepath.Dir(dest), storeMetaFile), meta); err != nil {
+			return err
+		}
+		if err := os.Rename(src, dest); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lock takes the lock of the store. The lock file is locked with an OS
+// file lock, which is released by the OS if wtf crashes or is killed, so a
+// lock file left behind does not block later runs.
+func (s *Store) lock() (func(), error) {
+	filename := filepath.Join(s.root, storeLockFile)
+	deadline := time.Now().Add(storeLockTimeout)
+	for {
+		f, err := os.OpenFile(filename, os.O_CREATE|os.O_RDWR, 0600)
+		if err != nil {
+			return nil, err
+		}
+		locked, err := lockFile(f)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		if locked {
+			if sameLockFile(f, filename) {
+				// the process ID is informational only
+				f.Truncate(0)
+				fmt.Fprintf(f, "%d\n", os.Getpid())
+				return func() { unlockFile(f, filename) }, nil
+			}
+			// removed by the previous owner after we opened it
+			f.Close()
+			continue
+		}
+		f.Close()
+		if time.Now().After(deadline) {
+			if pid, ok := lockOwner(filename); ok {
+				return nil, fmt.Errorf("store is locked by another process (%d)", pid)
+			}
+			return nil, errors.New("store is locked by another process")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// sameLockFile reports whether the open file f is still the file at
+// filename.
+func sameLockFile(f *os.File, filename string) bool {
+	a, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	b, err := os.Stat(filename)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(a, b)
+}
+
+// lockOwner returns the process ID recorded in the lock file filename.
+func lockOwner(filename string) (int, bool) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return 0, false
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid <= 0 {
+		return 0, false
+	}
+	return pid, true
+}
+
+func fileSHA256(filename string) (string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// writeJSONFile atomically replaces filename with the JSON encoding of v.
+func writeJSONFile(filename string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := filename + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filename)
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestNewStoreMigratesFlatLayout(t *testing.T) {
+	root := t.TempDir()
+
+	flat := map[string]string{
+		"1.5.7":     "binary 1.5.7",
+		"1.6.0":     "binary 1.6.0",
+		".DS_Store": "junk",
+		"README":    "not a version",
+	}
+	for name, content := range flat {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0700); err != nil {
+			t.Fatalf("could not write %s: %v", name, err)
+		}
+	}
+
+	s, err := NewStore(root)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	versions, err := s.Versions("terraform")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(versions) != 2 {
+		t.Fatalf("expected 2 migrated versions, got %v", versions)
+	}
+
+	for _, v := range versions {
+		content, err := os.ReadFile(s.BinaryPath("terraform", v))
+		if err != nil {
+			t.Errorf("migrated binary for %s not readable: %v", v, err)
+			continue
+		}
+		if string(content) != "binary "+v.String() {
+			t.Errorf("migrated binary for %s has content %q", v, content)
+		}
+		if _, err := os.Stat(filepath.Join(root, v.String())); !os.IsNotExist(err) {
+			t.Errorf("flat binary for %s should have been moved", v)
+		}
+		meta, err := s.Meta("terraform", v)
+		if err != nil {
+			t.Errorf("meta for %s not readable: %v", v, err)
+		} else if meta.SHA256 == "" || meta.Version != v.String() {
+			t.Errorf("unexpected meta for %s: %+v", v, meta)
+		}
+	}
+
+	for _, name := range []string{".DS_Store", "README"} {
+		if _, err := os.Stat(filepath.Join(root, name)); err != nil {
+			t.Errorf("unrelated file %s should be left alone: %v", name, err)
+		}
+	}
+
+	version, err := s.layoutVersion()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if version != storeLayoutVersion {
+		t.Errorf("layout version = %d, want %d", version, storeLayoutVersion)
+	}
+	if _, err := os.Stat(filepath.Join(root, storeLockFile)); !os.IsNotExist(err) {
+		t.Error("lock file should be removed after migration")
+	}
+}
+
+func TestNewStoreCompletesInterruptedMigration(t *testing.T) {
+	root := t.TempDir()
+	s := &Store{root: root}
+	v := mustVersions(t, "1.5.7")[0]
+
+	// binary already moved, flat copy still present, no layout marker
+	if err := os.MkdirAll(filepath.Dir(s.BinaryPath("terraform", v)), 0700); err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+	if err := os.WriteFile(s.BinaryPath("terraform", v), []byte("moved"), 0700); err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "1.5.7"), []byte("moved"), 0700); err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+
+	if _, err := NewStore(root); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "1.5.7")); !os.IsNotExist(err) {
+		t.Error("redundant flat binary should be removed")
+	}
+	content, err := os.ReadFile(s.BinaryPath("terraform", v))
+	if err != nil || string(content) != "moved" {
+		t.Errorf("migrated binary should be kept, got %q (%v)", content, err)
+	}
+}
+
+func TestNewStoreRejectsNewerLayout(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, storeLayoutFile), []byte(`{"version": 99}`), 0600); err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+
+	_, err := NewStore(root)
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if !strings.Contains(err.Error(), "please upgrade wtf") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestStoreInstall(t *testing.T) {
+	s, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	v := mustVersions(t, "1.6.2")[0]
+
+	filename, err := s.Install("terraform", v, []byte("binary"), "https://example.com/terraform.zip")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if filename != s.BinaryPath("terraform", v) {
+		t.Errorf("Install() = %q, want %q", filename, s.BinaryPath("terraform", v))
+	}
+
+	info, err := os.Stat(filename)
+	if err != nil {
+		t.Fatalf("installed binary should exist: %v", err)
+	}
+	if info.Mode().Perm()&0100 == 0 {
+		t.Error("installed binary should be executable")
+	}
+
+	versions, err := s.Versions("terraform")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(versions) != 1 || !versions[0].Equal(v) {
+		t.Errorf("Versions() = %v, want [%s]", versions, v)
+	}
+
+	meta, err := s.Meta("terraform", v)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if meta.Source != "https://example.com/terraform.zip" {
+		t.Errorf("meta.Source = %q", meta.Source)
+	}
+
+	others, err := s.Versions("tofu")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(others) != 0 {
+		t.Errorf("expected no versions for other tool, got %v", others)
+	}
+}
+
+func TestStoreLock(t *testing.T) {
+	timeout := storeLockTimeout
+	storeLockTimeout = 200 * time.Millisecond
+	t.Cleanup(func() { storeLockTimeout = timeout })
+
+	tests := []struct {
+		name        string
+		leftBehind  bool
+		held        bool
+		expectError bool
+	}{
+		{name: "unlocked"},
+		{name: "left behind by a crashed process", leftBehind: true},
+		{name: "held by another process", held: true, expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Store{root: t.TempDir()}
+			filename := filepath.Join(s.root, storeLockFile)
+			if tt.leftBehind {
+				if err := os.WriteFile(filename, []byte("4194304\n"), 0600); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+			if tt.held {
+				unlock, err := s.lock()
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				defer unlock()
+			}
+
+			unlock, err := s.lock()
+			if tt.expectError {
+				if err == nil {
+					unlock()
+					t.Error("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if pid, ok := lockOwner(filename); !ok || pid != os.Getpid() {
+				t.Errorf("lockOwner() = %d, %v, want %d", pid, ok, os.Getpid())
+			}
+			unlock()
+			if _, err := os.Stat(filename); !os.IsNotExist(err) {
+				t.Error("lock file should be removed by unlock")
+			}
+		})
+	}
+}
+
+func TestStoreLockExclusive(t *testing.T) {
+	s := &Store{root: t.TempDir()}
+
+	var holders, overlaps atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			unlock, err := s.lock()
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			if holders.Add(1) > 1 {
+				overlaps.Add(1)
+			}
+			time.Sleep(10 * time.Millisecond)
+			holders.Add(-1)
+			unlock()
+		}()
+	}
+	wg.Wait()
+
+	if n := overlaps.Load(); n > 0 {
+		t.Errorf("lock was held %d times by more than one holder", n)
+	}
+}
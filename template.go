@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"text/template"
+)
+
+// templateFuncs are the functions available in wrapper templates. Like in
+// other template libraries the value a function operates on comes last, so
+// they can be used in pipelines: {{env "TF_LOG" | default "off"}}.
+var templateFuncs = template.FuncMap{
+	"shellquote": shellquoteValue,
+	"env":        os.Getenv,
+	"fileExists": fileExists,
+	"default":    defaultValue,
+	"quote":      quote,
+	"join":       join,
+	"hasPrefix":  hasPrefix,
+}
+
+// templateData is the context wrapper templates are rendered with.
+type templateData struct {
+	// TerraformBin is the path of the binary of the tool.
+	TerraformBin string
+	// Command is the binary and its arguments joined with plain spaces.
+	Command string
+	// QuotedCommand is the binary and its arguments quoted for POSIX
+	// shells.
+	QuotedCommand string
+	Args          []string
+	// Subcommand is the first argument which is not an option, e.g. plan
+	// for `terraform -chdir=live plan`.
+	Subcommand string
+	Verbose    bool
+	// Version is the version of the tool being run.
+	Version    string
+	WorkingDir string
+	// Workspace is the selected Terraform workspace.
+	Workspace string
+	// ConstraintSource is the file the version constraint was read from.
+	ConstraintSource string
+}
+
+func newTemplateData(command string, rc runContext, verbose bool) templateData {
+	argv := append([]string{command}, rc.Args...)
+	data := templateData{
+		TerraformBin:     command,
+		Command:          strings.Join(argv, " "),
+		QuotedCommand:    shellquote(argv...),
+		Args:             rc.Args,
+		Subcommand:       subcommand(rc.Args),
+		Verbose:          verbose,
+		WorkingDir:       rc.WorkingDir,
+		ConstraintSource: rc.ConstraintSource,
+	}
+	if rc.Version != nil {
+		data.Version = rc.Version.String()
+	}
+	if rc.WorkingDir != "" {
+		data.Workspace = currentWorkspace(rc.WorkingDir)
+	}
+	return data
+}
+
+// subcommand returns the first argument which is not an option.
+func subcommand(args []string) string {
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "-") {
+			return arg
+		}
+	}
+	return ""
+}
+
+// currentWorkspace returns the Terraform workspace selected in dir: the
+// value of TF_WORKSPACE or the workspace recorded in the data directory.
+func currentWorkspace(dir string) string {
+	if ws := os.Getenv("TF_WORKSPACE"); ws != "" {
+		return ws
+	}
+	dataDir := os.Getenv("TF_DATA_DIR")
+	if dataDir == "" {
+		dataDir = ".terraform"
+	}
+	if !filepath.IsAbs(dataDir) {
+		dataDir = filepath.Join(dir, dataDir)
+	}
+	data, err := os.ReadFile(filepath.Join(dataDir, "environment"))
+	if err != nil || strings.TrimSpace(string(data)) == "" {
+		return "default"
+	}
+	return strings.TrimSpace(string(data))
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// defaultValue returns value unless it is empty, i.e. the zero value of its
+// type or an empty list.
+func defaultValue(def, value any) any {
+	if value == nil {
+		return def
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map, reflect.String:
+		if v.Len() == 0 {
+			return def
+		}
+	default:
+		if v.IsZero() {
+			return def
+		}
+	}
+	return value
+}
+
+// quote returns value as a double quoted string for POSIX shells: $, `, "
+// and \ are escaped, so the shell does not expand anything inside.
+func quote(value any) string {
+	return `"` + shellDoubleQuoteEscaper.Replace(fmt.Sprint(value)) + `"`
+}
+
+var shellDoubleQuoteEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", `\$`, "`", "\\`")
+
+func join(sep string, list []string) string {
+	return strings.Join(list, sep)
+}
+
+func hasPrefix(prefix, s string) bool {
+	return strings.HasPrefix(s, prefix)
+}
+
+// shellquote quotes each word for POSIX shells and joins them with spaces.
+// Words consisting of safe characters only are left as they are.
+func shellquote(words ...string) string {
+	quoted := make([]string, len(words))
+	for i, word := range words {
+		if word != "" && strings.Trim(word, shellSafeChars) == "" {
+			quoted[i] = word
+			continue
+		}
+		quoted[i] = "'" + strings.ReplaceAll(word, "'", `'"'"'`) + "'"
+	}
+	return strings.Join(quoted, " ")
+}
+
+const shellSafeChars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_@%+=:,./-"
+
+// shellquoteValue is the shellquote template function. It accepts a string
+// or a list of strings such as .Args.
+func shellquoteValue(v any) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return shellquote(v), nil
+	case []string:
+		return shellquote(v...), nil
+	default:
+		return "", fmt.Errorf("shellquote: unsupported type %T", v)
+	}
+}
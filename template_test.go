@@ -0,0 +1,204 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+func TestTemplateFuncs(t *testing.T) {
+	t.Setenv("WTF_TEST_SET", "value")
+	t.Setenv("WTF_TEST_EMPTY", "")
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "secrets.yml")
+	if err := os.WriteFile(existing, nil, 0644); err != nil {
+		t.Fatalf("could not write file: %v", err)
+	}
+
+	tests := []struct {
+		template string
+		expected string
+	}{
+		{template: `{{env "WTF_TEST_SET"}}`, expected: "value"},
+		{template: `{{env "WTF_TEST_EMPTY" | default "fallback"}}`, expected: "fallback"},
+		{template: `{{env "WTF_TEST_SET" | default "fallback"}}`, expected: "value"},
+		{template: `{{.Args | default "none"}}`, expected: "[plan -out=tf plan]"},
+		{template: `{{.Empty | default "none"}}`, expected: "none"},
+		{template: `{{.False | default true}}`, expected: "true"},
+		{template: `{{fileExists .Existing}} {{fileExists .Missing}}`, expected: "true false"},
+		{template: `{{quote "say \"hi\""}}`, expected: `"say \"hi\""`},
+		{template: `{{.Args | join ","}}`, expected: "plan,-out=tf plan"},
+		{template: `{{if hasPrefix "pl" "plan"}}yes{{end}}{{if "apply" | hasPrefix "pl"}}no{{end}}`, expected: "yes"},
+		{template: `{{shellquote "a b"}}`, expected: "'a b'"},
+	}
+
+	data := map[string]any{
+		"Args":     []string{"plan", "-out=tf plan"},
+		"Empty":    []string{},
+		"False":    false,
+		"Existing": existing,
+		"Missing":  filepath.Join(dir, "missing"),
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.template, func(t *testing.T) {
+			tmpl, err := template.New("test").Funcs(templateFuncs).Parse(tt.template)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var out strings.Builder
+			if err := tmpl.Execute(&out, data); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if out.String() != tt.expected {
+				t.Errorf("rendered %q, want %q", out.String(), tt.expected)
+			}
+		})
+	}
+}
+
+func TestNewTemplateData(t *testing.T) {
+	t.Setenv("TF_WORKSPACE", "")
+	t.Setenv("TF_DATA_DIR", "")
+	dir := t.TempDir()
+	v := mustVersions(t, "1.6.2")[0]
+	rc := runContext{
+		Version:          v,
+		Args:             []string{"-chdir=live", "apply", "-auto-approve"},
+		WorkingDir:       dir,
+		ConstraintSource: filepath.Join(dir, "versions.tf"),
+	}
+
+	data := newTemplateData("/bin/terraform", rc, true)
+	if data.Subcommand != "apply" {
+		t.Errorf("Subcommand = %q, want apply", data.Subcommand)
+	}
+	if data.Version != "1.6.2" {
+		t.Errorf("Version = %q, want 1.6.2", data.Version)
+	}
+	if data.WorkingDir != dir || data.ConstraintSource != rc.ConstraintSource {
+		t.Errorf("unexpected WorkingDir %q or ConstraintSource %q", data.WorkingDir, data.ConstraintSource)
+	}
+	if data.Workspace != "default" {
+		t.Errorf("Workspace = %q, want default", data.Workspace)
+	}
+	if data.QuotedCommand != "/bin/terraform -chdir=live apply -auto-approve" {
+		t.Errorf("QuotedCommand = %q", data.QuotedCommand)
+	}
+}
+
+func TestSubcommand(t *testing.T) {
+	tests := []struct {
+		args     []string
+		expected string
+	}{
+		{args: []string{"plan", "-out=tfplan"}, expected: "plan"},
+		{args: []string{"-chdir=live", "apply"}, expected: "apply"},
+		{args: []string{"-version"}, expected: ""},
+		{args: nil, expected: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			if s := subcommand(tt.args); s != tt.expected {
+				t.Errorf("subcommand() = %q, want %q", s, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCurrentWorkspace(t *testing.T) {
+	tests := []struct {
+		name        string
+		envVar      string
+		dataDir     string
+		environment string
+		expected    string
+	}{
+		{name: "default", expected: "default"},
+		{name: "selected workspace", environment: "staging\n", expected: "staging"},
+		{name: "TF_WORKSPACE wins", envVar: "prod", environment: "staging", expected: "prod"},
+		{name: "TF_DATA_DIR", dataDir: "data", environment: "dev", expected: "dev"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TF_WORKSPACE", tt.envVar)
+			t.Setenv("TF_DATA_DIR", tt.dataDir)
+			dir := t.TempDir()
+			if tt.environment != "" {
+				dataDir := tt.dataDir
+				if dataDir == "" {
+					dataDir = ".terraform"
+				}
+				if err := os.MkdirAll(filepath.Join(dir, dataDir), 0755); err != nil {
+					t.Fatalf("could not create dir: %v", err)
+				}
+				if err := os.WriteFile(filepath.Join(dir, dataDir, "environment"), []byte(tt.environment), 0644); err != nil {
+					t.Fatalf("could not write file: %v", err)
+				}
+			}
+			if ws := currentWorkspace(dir); ws != tt.expected {
+				t.Errorf("currentWorkspace() = %q, want %q", ws, tt.expected)
+			}
+		})
+	}
+}
+
+func TestShellquote(t *testing.T) {
+	tests := []struct {
+		words    []string
+		expected string
+	}{
+		{words: []string{"plan", "-out=tfplan"}, expected: "plan -out=tfplan"},
+		{words: []string{""}, expected: "''"},
+		{words: []string{"a b"}, expected: "'a b'"},
+		{words: []string{"$HOME", "`id`", "a;b"}, expected: "'$HOME' '`id`' 'a;b'"},
+		{words: []string{"it's"}, expected: `'it'"'"'s'`},
+		{words: []string{"-var", "tags={a=\"b c\"}"}, expected: `-var 'tags={a="b c"}'`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expected, func(t *testing.T) {
+			if s := shellquote(tt.words...); s != tt.expected {
+				t.Errorf("shellquote() = %s, want %s", s, tt.expected)
+			}
+		})
+	}
+}
+
+func TestQuote(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+	}{
+		{value: "plan", expected: `"plan"`},
+		{value: `say "hi"`, expected: `"say \"hi\""`},
+		{value: "$HOME `id`", expected: "\"\\$HOME \\`id\\`\""},
+		{value: `C:\x41`, expected: `"C:\\x41"`},
+		{value: "it's\nfine", expected: "\"it's\nfine\""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			s := quote(tt.value)
+			if s != tt.expected {
+				t.Errorf("quote() = %s, want %s", s, tt.expected)
+			}
+			if runtime.GOOS == "windows" {
+				return
+			}
+			out, err := exec.Command("sh", "-c", "printf %s "+s).Output()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(out) != tt.value {
+				t.Errorf("sh read %q, want %q", out, tt.value)
+			}
+		})
+	}
+}
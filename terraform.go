@@ -3,16 +3,16 @@ package main
 import (
 	"archive/zip"
 	"bytes"
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"os"
-	"path/filepath"
-	"runtime"
-	"sort"
+	"os/signal"
 	"strings"
 	"time"
 
@@ -26,37 +26,37 @@ var httpClient = &http.Client{
 }
 
 type Terraform struct {
+	tool     Tool
 	location string
 	verbose  bool
 	versions ver.Collection
+	store    *Store
+	sources  []ReleaseSource
+	// gracePeriod is the time the tool has to exit after a signal was
+	// passed on before it is killed.
+	gracePeriod time.Duration
 }
 
-func NewTerraform(location string, verbose bool) (*Terraform, error) {
-	location, err := expandPath(location)
+func NewTerraform(location string, tool Tool, cache *httpCache, verbose bool) (*Terraform, error) {
+	store, err := NewStore(location)
 	if err != nil {
 		return nil, err
 	}
-	err = createDir(location)
+	sources, err := newReleaseSources(tool, cache)
 	if err != nil {
-		return nil, err
+		return nil, withExitCode(exitConfig, err)
 	}
 	tf := &Terraform{
-		location: strings.TrimRight(location, "/"),
+		tool:     tool,
+		location: store.ToolDir(tool.Name),
 		verbose:  verbose,
+		store:    store,
+		sources:  sources,
 	}
-	files, err := os.ReadDir(tf.location)
+	tf.versions, err = store.Versions(tool.Name)
 	if err != nil {
 		return tf, err
 	}
-
-	for _, f := range files {
-		v, err := ver.NewVersion(f.Name())
-		if err != nil {
-			// Skip entries that don't look like version numbers (e.g., .DS_Store)
-			continue
-		}
-		tf.versions = append(tf.versions, v)
-	}
 	return tf, nil
 }
 
@@ -91,155 +91,156 @@ func (tf *Terraform) ListInstalled() ver.Collection {
 	return tf.versions
 }
 
-func (tf *Terraform) ListAvailable() (ver.Collection, error) {
-	out := ver.Collection{}
-
-	type releaseInfo struct {
-		Versions map[string]struct {
-			Builds []struct {
-				Os   string `json:"os"`
-				Arch string `json:"arch"`
-			} `json:"builds"`
-		} `json:"versions"`
-	}
-
-	url := "https://releases.hashicorp.com/terraform/index.json"
-	resp, err := httpClient.Get(url)
-	if err != nil {
-		return out, fmt.Errorf("could not download %s: %s", url, err.Error())
-	}
-	if resp.StatusCode < 200 || resp.StatusCode > 299 {
-		return out, fmt.Errorf("could not download %s: %s", url, resp.Status)
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return out, err
-	}
-
-	releases := releaseInfo{}
-	err = json.Unmarshal(body, &releases)
-	if err != nil {
-		return out, err
-	}
-
-	for vs, spec := range releases.Versions {
-		version, err := ver.NewVersion(vs)
-		if err != nil {
-			continue
-		}
-		for _, build := range spec.Builds {
-			if build.Arch == runtime.GOARCH && build.Os == runtime.GOOS {
-				out = append(out, version)
-			}
+// ListReleases lists the releases available for download from the first
+// source which can be reached.
+func (tf *Terraform) ListReleases() ([]Release, error) {
+	errs := []string{}
+	for _, s := range tf.sources {
+		out, err := s.List(tf.tool)
+		if err == nil {
+			return out, nil
 		}
+		logger.Log(context.Background(), verboseLevel(tf.verbose), "could not list versions", "source", s.String(), "error", err.Error())
+		errs = append(errs, fmt.Sprintf("%s: %s", s, err.Error()))
 	}
+	return []Release{}, fmt.Errorf("could not list versions of %s:\n  %s", tf.tool.Name, strings.Join(errs, "\n  "))
+}
 
-	sort.Sort(out)
-	return out, nil
+// runContext describes a single invocation of a tool.
+type runContext struct {
+	// Tool is the name of the tool.
+	Tool    string
+	Version *ver.Version
+	Args    []string
+	// WorkingDir is the directory the tool is run in.
+	WorkingDir string
+	// ConstraintSource is the file the version constraint was read from.
+	ConstraintSource string
+	// Env is the environment of the tool, nil for the environment of wtf.
+	Env []string
 }
 
-func (tf *Terraform) Run(v *ver.Version, args []string, w wrapper) (*os.ProcessState, error) {
-	wd, err := os.Getwd()
-	if err != nil {
-		return nil, err
-	}
+// Run runs the tool, using the wrapper if it has a script. The rendered
+// script is removed on every return path.
+func (tf *Terraform) Run(rc runContext, w wrapper) (status *os.ProcessState, err error) {
+	defer func() {
+		if cerr := w.Cleanup(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
 
 	pa := os.ProcAttr{
 		Files: []*os.File{os.Stdin, os.Stdout, os.Stderr},
-		Dir:   wd,
+		Dir:   rc.WorkingDir,
+		Env:   rc.Env,
 	}
 
-	bin := filepath.Join(tf.location, v.String())
+	// Signals sent to wtf, e.g. by a CI runner cancelling a job, are passed
+	// on so the tool can release its state lock; wtf keeps running until
+	// the tool exits so the script is removed. They are caught before the
+	// script is written, so it is removed if wtf is interrupted earlier.
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, shutdownSignals...)
+	defer signal.Stop(sigs)
 
-	cmd, args, err := w.Wrap(bin, args, tf.verbose)
+	bin := tf.store.BinaryPath(tf.tool.Name, rc.Version)
+
+	cmd, args, err := w.WrapContext(bin, rc, tf.verbose)
 	if err != nil {
 		return nil, err
 	}
 
-	proc, err := os.StartProcess(cmd, append([]string{"terraform"}, args...), &pa)
-	if err != nil {
-		return nil, err
+	select {
+	case sig := <-sigs:
+		return nil, fmt.Errorf("interrupted by %s before %s was started", sig, tf.tool.Name)
+	default:
 	}
 
-	status, err := proc.Wait()
+	var terminal bool
+	pa.Sys, terminal = processGroup()
+
+	proc, err := os.StartProcess(cmd, append([]string{tf.tool.Name}, args...), &pa)
 	if err != nil {
-		return status, err
+		return nil, err
 	}
 
-	return status, w.Cleanup()
-}
+	// a wrapper script does not pass signals on to the tool it started
+	f := forwardSignals(proc, cmd != bin && !terminal, terminal, tf.gracePeriod, sigs)
+	defer f.stop()
 
-// fetchExpectedChecksum downloads the SHA256SUMS file and returns the expected
-// checksum for the given zip filename.
-func fetchExpectedChecksum(v *ver.Version, zipFilename string) (string, error) {
-	url := fmt.Sprintf("https://releases.hashicorp.com/terraform/%s/terraform_%s_SHA256SUMS", v.String(), v.String())
+	return proc.Wait()
+}
 
-	resp, err := httpClient.Get(url)
-	if err != nil {
-		return "", fmt.Errorf("could not download checksums from %s: %s", url, err.Error())
-	}
-	if resp.StatusCode < 200 || resp.StatusCode > 299 {
-		return "", fmt.Errorf("could not download checksums from %s: %s", url, resp.Status)
+// Exec replaces wtf with the tool, so signals, the terminal and the exit
+// code are the tool's own. It only returns if the tool could not be
+// started. Wrappers and post hooks need wtf to wait for the tool and are
+// run with Run instead.
+func (tf *Terraform) Exec(rc runContext) error {
+	env := rc.Env
+	if env == nil {
+		env = os.Environ()
 	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", fmt.Errorf("could not read checksums: %s", err.Error())
+	if err := os.Chdir(rc.WorkingDir); err != nil {
+		return err
 	}
+	bin := tf.store.BinaryPath(tf.tool.Name, rc.Version)
+	return execProcess(bin, append([]string{tf.tool.Name}, rc.Args...), env)
+}
 
-	// SHA256SUMS format: "<hash>  <filename>\n"
-	for _, line := range strings.Split(string(body), "\n") {
-		parts := strings.Fields(line)
-		if len(parts) != 2 {
-			continue
+// DownloadVersion downloads a version from the first source providing it
+// and installs it into the store.
+func (tf *Terraform) DownloadVersion(v *ver.Version) (string, error) {
+	errs := []string{}
+	for _, s := range tf.sources {
+		filename, err := tf.downloadFrom(s, v)
+		if err == nil {
+			return filename, nil
 		}
-		if parts[1] == zipFilename {
-			return parts[0], nil
+		if errors.Is(err, errChecksumMismatch) {
+			return "", err
 		}
+		logger.Log(context.Background(), verboseLevel(tf.verbose), "could not download", "source", s.String(), "error", err.Error())
+		errs = append(errs, fmt.Sprintf("%s: %s", s, err.Error()))
 	}
-
-	return "", fmt.Errorf("checksum not found for %s", zipFilename)
+	return "", fmt.Errorf("no source provides %s %s:\n  %s", tf.tool.Name, v.String(), strings.Join(errs, "\n  "))
 }
 
-func (tf *Terraform) DownloadVersion(v *ver.Version) (string, error) {
-	zipFilename := fmt.Sprintf("terraform_%s_%s_%s.zip", v.String(), runtime.GOOS, runtime.GOARCH)
-	url := fmt.Sprintf("https://releases.hashicorp.com/terraform/%s/%s", v.String(), zipFilename)
-	filename := filepath.Join(tf.location, v.String())
+func (tf *Terraform) downloadFrom(s ReleaseSource, v *ver.Version) (string, error) {
+	asset := tf.tool.assetName(v)
 
 	// Fetch expected checksum first
-	expectedChecksum, err := fetchExpectedChecksum(v, zipFilename)
+	expectedChecksum, err := s.Checksum(tf.tool, v, asset)
 	if err != nil {
 		return "", err
 	}
 
-	resp, err := httpClient.Get(url)
+	r, size, err := s.Download(tf.tool, v, asset)
 	if err != nil {
-		return "", fmt.Errorf("could not download %s: %s", url, err.Error())
-	}
-	if resp.StatusCode < 200 || resp.StatusCode > 299 {
-		return "", fmt.Errorf("could not download %s: %s", url, resp.Status)
+		return "", err
 	}
-	defer resp.Body.Close()
+	defer r.Close()
 
-	bar := progressbar.DefaultBytes(
-		resp.ContentLength,
-		fmt.Sprintf("Downloading terraform %s", v.String()),
-	)
+	description := fmt.Sprintf("Downloading %s %s", tf.tool.Name, v.String())
+	bar := progressbar.DefaultBytes(size, description)
+	if !logger.Enabled(context.Background(), slog.LevelInfo) {
+		bar = progressbar.DefaultBytesSilent(size, description)
+	}
 
-	body, err := io.ReadAll(io.TeeReader(resp.Body, bar))
+	body, err := io.ReadAll(io.TeeReader(r, bar))
 	if err != nil {
 		return "", err
 	}
-	fmt.Println() // newline after progress bar
 
 	// Verify checksum
 	hash := sha256.Sum256(body)
 	actualChecksum := hex.EncodeToString(hash[:])
 	if actualChecksum != expectedChecksum {
-		return "", fmt.Errorf("checksum mismatch for %s: expected %s, got %s", zipFilename, expectedChecksum, actualChecksum)
+		return "", fmt.Errorf("%w for %s: expected %s, got %s", errChecksumMismatch, asset, expectedChecksum, actualChecksum)
+	}
+
+	origin := fmt.Sprintf("%s (%s)", s, asset)
+	if !strings.HasSuffix(asset, ".zip") {
+		return tf.store.Install(tf.tool.Name, v, body, origin)
 	}
 
 	zipReader, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
@@ -247,13 +248,9 @@ func (tf *Terraform) DownloadVersion(v *ver.Version) (string, error) {
 		return "", err
 	}
 
-	// On Windows, the binary is named terraform.exe
-	expectedName := "terraform"
-	if runtime.GOOS == "windows" {
-		expectedName = "terraform.exe"
-	}
+	// On Windows, the binary is named e.g. terraform.exe
+	expectedName := binaryName(tf.tool.Name)
 
-	unpacked := false
 	for _, zipped := range zipReader.File {
 		if zipped.Name != expectedName {
 			continue
@@ -270,32 +267,8 @@ func (tf *Terraform) DownloadVersion(v *ver.Version) (string, error) {
 			return "", err
 		}
 
-		dest, err := os.Create(filename)
-		if err != nil {
-			return "", err
-		}
-
-		_, err = dest.Write(b)
-		if err != nil {
-			dest.Close()
-			return "", err
-		}
-
-		err = dest.Sync()
-		dest.Close()
-		if err != nil {
-			return "", err
-		}
-		unpacked = true
-	}
-
-	if !unpacked {
-		return "", fmt.Errorf("could not find file `%s` in downloaded zip", expectedName)
-	}
-
-	if err := os.Chmod(filename, 0700); err != nil {
-		return "", err
+		return tf.store.Install(tf.tool.Name, v, b, origin)
 	}
 
-	return filename, nil
+	return "", fmt.Errorf("could not find file `%s` in downloaded zip", expectedName)
 }
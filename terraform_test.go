@@ -1,6 +1,12 @@
 package main
 
 import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
 	"testing"
 
 	ver "github.com/hashicorp/go-version"
@@ -234,6 +240,19 @@ func TestListInstalled(t *testing.T) {
 	}
 }
 
+func TestNewTerraformInvalidSource(t *testing.T) {
+	tool := knownTools["terraform"]
+	tool.Sources = []sourceConfig{{Type: sourceHTTP}}
+
+	_, err := NewTerraform(t.TempDir(), tool, nil, false)
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if got := exitCode(err); got != exitConfig {
+		t.Errorf("exitCode() = %d, want %d", got, exitConfig)
+	}
+}
+
 // helper function to check substring
 func contains(s, substr string) bool {
 	return len(s) >= len(substr) && (s == substr || len(substr) == 0 ||
@@ -248,3 +267,71 @@ func findSubstring(s, substr string) bool {
 	}
 	return false
 }
+
+func TestRunEnvironment(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("wrapper scripts require a POSIX shell")
+	}
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	store, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tf := &Terraform{tool: knownTools["terraform"], store: store}
+	dir := t.TempDir()
+	rc := runContext{
+		Version:    mustVersions(t, "1.6.2")[0],
+		WorkingDir: dir,
+		Env:        []string{"PATH=" + os.Getenv("PATH"), "WTF_TEST_INJECTED=injected"},
+	}
+
+	w := wrapper{ScriptTemplate: "#!/bin/sh\necho \"$WTF_TEST_INJECTED\" > out"}
+	if _, err := tf.Run(rc, w); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	content, err := os.ReadFile(filepath.Join(dir, "out"))
+	if err != nil || string(content) != "injected\n" {
+		t.Errorf("tool saw %q (%v), want the injected variable", content, err)
+	}
+}
+
+// TestExec runs the test binary again to replace it with a fake tool; the
+// tool must run with the same process ID.
+func TestExec(t *testing.T) {
+	if !canExec {
+		t.Skip("replacing the process is not supported")
+	}
+	if dir := os.Getenv("WTF_TEST_EXEC_STORE"); dir != "" {
+		store, err := NewStore(dir)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		tf := &Terraform{tool: knownTools["terraform"], store: store}
+		rc := runContext{Version: mustVersions(t, "1.6.2")[0], Args: []string{"plan", "-input=false"}, WorkingDir: dir}
+		t.Fatalf("exec failed: %v", tf.Exec(rc))
+	}
+
+	dir := t.TempDir()
+	store, err := NewStore(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	bin := store.BinaryPath("terraform", mustVersions(t, "1.6.2")[0])
+	if err := os.MkdirAll(filepath.Dir(bin), 0755); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := os.WriteFile(bin, []byte("#!/bin/sh\necho \"$$ $*\"\nexit 3\n"), 0755); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestExec$")
+	cmd.Env = append(os.Environ(), "WTF_TEST_EXEC_STORE="+dir)
+	out, err := cmd.Output()
+	if cmd.ProcessState == nil || cmd.ProcessState.ExitCode() != 3 {
+		t.Fatalf("expected exit code 3, got %v: %s", err, out)
+	}
+	expected := strconv.Itoa(cmd.Process.Pid) + " plan -input=false"
+	if got := strings.TrimSpace(string(out)); got != expected {
+		t.Errorf("tool printed %q, want %q", got, expected)
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	releasesHashiCorp = "hashicorp"
+	releasesGitHub    = "github"
+)
+
+// Tool describes a binary managed by wtf: where its releases are published
+// and how projects declare the version they need.
+type Tool struct {
+	// Name is the name of the binary and of its directory in the store.
+	Name string `yaml:"-"`
+	// Releases is the kind of release layout the tool is published with.
+	Releases string `yaml:"releases"`
+	// Repo is the GitHub repository (owner/name) for GitHub style releases.
+	Repo string `yaml:"repo"`
+	// VersionFile is the name of a file containing a version or constraint,
+	// e.g. .opentofu-version. It is searched in the working directory and
+	// its parents.
+	VersionFile string `yaml:"version_file"`
+	// ConfigFiles is a glob matching the HCL files in the working directory
+	// which may contain a constraint in the attribute ConfigAttribute
+	// (defaults to required_version) of a block named ConfigBlock. If
+	// ConfigBlock is empty, the attribute is read from the top level.
+	ConfigFiles     string `yaml:"config_files"`
+	ConfigBlock     string `yaml:"config_block"`
+	ConfigAttribute string `yaml:"config_attribute"`
+	// Asset and Checksums are the names of the release file for the current
+	// platform and of the SHA256SUMS file of a release. The placeholders
+	// {name}, {version}, {os}, {arch} and {exe} (.exe on Windows) are
+	// replaced. Assets ending in .zip are unpacked, any other asset is the
+	// binary itself.
+	Asset     string `yaml:"asset"`
+	Checksums string `yaml:"checksums"`
+	// Detect lets `wtf exec` pick this tool instead of terraform when its
+	// VersionFile is found.
+	Detect bool `yaml:"-"`
+	// Sources are tried in order to list and download releases. If empty,
+	// the global sources are used.
+	Sources []sourceConfig `yaml:"sources"`
+}
+
+var knownTools = map[string]Tool{
+	"terraform": {
+		Name:        "terraform",
+		Releases:    releasesHashiCorp,
+		ConfigFiles: "versions.tf",
+		ConfigBlock: "terraform",
+	},
+	"tofu": {
+		Name:        "tofu",
+		Releases:    releasesGitHub,
+		Repo:        "opentofu/opentofu",
+		VersionFile: ".opentofu-version",
+		ConfigFiles: "versions.tf",
+		ConfigBlock: "terraform",
+		Detect:      true,
+	},
+	"packer": {
+		Name:        "packer",
+		Releases:    releasesHashiCorp,
+		VersionFile: ".packer-version",
+		ConfigFiles: "*.pkr.hcl",
+		ConfigBlock: "packer",
+	},
+	"vault": {
+		Name:        "vault",
+		Releases:    releasesHashiCorp,
+		VersionFile: ".vault-version",
+	},
+	"consul": {
+		Name:        "consul",
+		Releases:    releasesHashiCorp,
+		VersionFile: ".consul-version",
+	},
+	"nomad": {
+		Name:        "nomad",
+		Releases:    releasesHashiCorp,
+		VersionFile: ".nomad-version",
+	},
+	"terragrunt": {
+		Name:            "terragrunt",
+		Releases:        releasesGitHub,
+		Repo:            "gruntwork-io/terragrunt",
+		VersionFile:     ".terragrunt-version",
+		ConfigFiles:     "terragrunt.hcl",
+		ConfigAttribute: "terragrunt_version_constraint",
+		Asset:           "{name}_{os}_{arch}{exe}",
+		Checksums:       "SHA256SUMS",
+	},
+}
+
+const (
+	defaultAsset     = "{name}_{version}_{os}_{arch}.zip"
+	defaultChecksums = "{name}_{version}_SHA256SUMS"
+)
+
+const defaultTool = "terraform"
+
+// getTool returns a built-in tool or a tool defined in the `tools` section
+// of the configuration. Settings of a configured tool override the settings
+// of the built-in tool of the same name.
+func (c *conf) getTool(name string) (Tool, error) {
+	t, known := knownTools[name]
+	configured, ok := c.Tools[name]
+	if !known && !ok {
+		return Tool{}, fmt.Errorf("unknown tool '%s', known tools are: %s", name, strings.Join(c.toolNames(), ", "))
+	}
+
+	if ok {
+		t.Name = name
+		if configured.Releases != "" {
+			t.Releases = configured.Releases
+		}
+		if configured.Repo != "" {
+			t.Repo = configured.Repo
+		}
+		if configured.VersionFile != "" {
+			t.VersionFile = configured.VersionFile
+		}
+		if configured.ConfigFiles != "" {
+			t.ConfigFiles = configured.ConfigFiles
+		}
+		if configured.ConfigBlock != "" {
+			t.ConfigBlock = configured.ConfigBlock
+		}
+		if configured.ConfigAttribute != "" {
+			t.ConfigAttribute = configured.ConfigAttribute
+		}
+		if configured.Asset != "" {
+			t.Asset = configured.Asset
+		}
+		if configured.Checksums != "" {
+			t.Checksums = configured.Checksums
+		}
+		if len(configured.Sources) > 0 {
+			t.Sources = configured.Sources
+		}
+	}
+
+	if t.Releases == "" {
+		t.Releases = releasesHashiCorp
+	}
+	if t.Releases != releasesHashiCorp && t.Releases != releasesGitHub {
+		return t, fmt.Errorf("tool '%s' has unknown releases '%s'", name, t.Releases)
+	}
+	if t.Releases == releasesGitHub && t.Repo == "" {
+		return t, fmt.Errorf("tool '%s' has github releases but no repo", name)
+	}
+	if len(t.Sources) == 0 {
+		t.Sources = c.Sources
+	}
+	return t, nil
+}
+
+func (c *conf) toolNames() []string {
+	names := []string{}
+	for name := range knownTools {
+		names = append(names, name)
+	}
+	for name := range c.Tools {
+		if _, ok := knownTools[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// splitToolVersion splits arguments such as `tofu@1.6.0` into tool name and
+// version. If no tool is given the name is empty.
+func splitToolVersion(arg string) (string, string) {
+	if tool, version, ok := strings.Cut(arg, "@"); ok {
+		return tool, version
+	}
+	return "", arg
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+
+	ver "github.com/hashicorp/go-version"
+)
+
+// Which returns the path of the binary of the newest installed version
+// satisfying c. If there is none and install is set, the newest release
+// satisfying c is installed first.
+func (tf *Terraform) Which(c ver.Constraints, install bool) (string, error) {
+	v, err := tf.FindLatest(c)
+	if err != nil {
+		if !install {
+			return "", withExitCode(exitNotInstalled, err)
+		}
+		releases, err := tf.ListReleases()
+		if err != nil {
+			return "", withExitCode(exitDownload, err)
+		}
+		v = newestRelease(matchingReleases(releases, c))
+		if v == nil {
+			return "", withExitCode(exitNotInstalled, fmt.Errorf("no release of %s matches %s", tf.tool.Name, c.String()))
+		}
+		if _, err := tf.DownloadVersion(v); err != nil {
+			return "", withExitCode(exitDownload, err)
+		}
+	}
+	return tf.store.BinaryPath(tf.tool.Name, v), nil
+}
+
+// newestRelease returns the newest release which was not withdrawn, or nil.
+// Prereleases are only considered if there is no other release, e.g. if the
+// constraint pins a prerelease.
+func newestRelease(releases []Release) *ver.Version {
+	var newest, prerelease *ver.Version
+	for _, r := range releases {
+		if r.Withdrawn {
+			continue
+		}
+		if r.Prerelease || r.Version.Prerelease() != "" {
+			if prerelease == nil || r.Version.GreaterThan(prerelease) {
+				prerelease = r.Version
+			}
+			continue
+		}
+		if newest == nil || r.Version.GreaterThan(newest) {
+			newest = r.Version
+		}
+	}
+	if newest == nil {
+		return prerelease
+	}
+	return newest
+}
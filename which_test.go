@@ -0,0 +1,113 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestWhich(t *testing.T) {
+	tool := knownTools["terraform"]
+	archive := mustZip(t, binaryName("terraform"), "binary")
+	releases := mustLocalRelease(t, tool, "1.6.2", archive, sha256Hex(archive))
+
+	tests := []struct {
+		name          string
+		installed     []string
+		constraint    string
+		install       bool
+		expected      string
+		exitCode      int
+		errorContains string
+	}{
+		{name: "installed version", installed: []string{"1.5.7", "1.6.0"}, constraint: "~> 1.5", expected: "1.6.0"},
+		{name: "missing version", installed: []string{"1.5.7"}, constraint: "~> 1.6", exitCode: exitNotInstalled, errorContains: "no matching version"},
+		{name: "installs missing version", installed: []string{"1.5.7"}, constraint: "~> 1.6", install: true, expected: "1.6.2"},
+		{name: "installed version is preferred", installed: []string{"1.6.0"}, constraint: "~> 1.6", install: true, expected: "1.6.0"},
+		{name: "no matching release", constraint: "~> 2.0", install: true, exitCode: exitNotInstalled, errorContains: "no release of terraform matches"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, err := NewStore(t.TempDir())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			tf := &Terraform{
+				tool:     tool,
+				store:    store,
+				versions: mustVersions(t, tt.installed...),
+				sources:  []ReleaseSource{&localSource{path: releases}},
+			}
+
+			path, err := tf.Which(mustConstraint(t, tt.constraint), tt.install)
+			if tt.errorContains != "" {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				if !strings.Contains(err.Error(), tt.errorContains) {
+					t.Errorf("error %q does not contain %q", err.Error(), tt.errorContains)
+				}
+				if exitCode(err) != tt.exitCode {
+					t.Errorf("exit code = %d, want %d", exitCode(err), tt.exitCode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if expected := store.BinaryPath("terraform", mustVersions(t, tt.expected)[0]); path != expected {
+				t.Errorf("Which() = %q, want %q", path, expected)
+			}
+			if tt.install && tt.expected == "1.6.2" {
+				if _, err := os.Stat(path); err != nil {
+					t.Errorf("binary was not installed: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestNewestRelease(t *testing.T) {
+	withdrawn := newRelease(mustVersions(t, "1.9.9")[0])
+	withdrawn.Withdrawn = true
+	flagged := newRelease(mustVersions(t, "1.9.10")[0])
+	flagged.Prerelease = true
+
+	tests := []struct {
+		name     string
+		releases []Release
+		expected string
+	}{
+		{name: "newest", releases: releasesOf(t, "1.9.8", "1.5.7"), expected: "1.9.8"},
+		{name: "prerelease is skipped", releases: releasesOf(t, "1.9.8", "1.10.0-alpha20240926"), expected: "1.9.8"},
+		{name: "flagged prerelease is skipped", releases: append(releasesOf(t, "1.9.8"), flagged), expected: "1.9.8"},
+		{name: "withdrawn release is skipped", releases: append(releasesOf(t, "1.9.8"), withdrawn), expected: "1.9.8"},
+		{name: "only prereleases", releases: releasesOf(t, "1.10.0-alpha20240926", "1.10.0-beta1"), expected: "1.10.0-beta1"},
+		{name: "none"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := newestRelease(tt.releases)
+			if tt.expected == "" {
+				if got != nil {
+					t.Errorf("newestRelease() = %s, want nil", got)
+				}
+				return
+			}
+			if got == nil || got.String() != tt.expected {
+				t.Errorf("newestRelease() = %v, want %s", got, tt.expected)
+			}
+		})
+	}
+}
+
+func releasesOf(t *testing.T, versions ...string) []Release {
+	t.Helper()
+	out := []Release{}
+	for _, v := range mustVersions(t, versions...) {
+		out = append(out, newRelease(v))
+	}
+	return out
+}
@@ -2,49 +2,62 @@ package main
 
 import (
 	"bytes"
+	"fmt"
 	"os"
-	"strings"
+	"path/filepath"
 	"text/template"
 )
 
 type wrapper struct {
-	ScriptTemplate string `yaml:"script_template"`
-	tmpfile        *os.File
+	// Name identifies a wrapper in the list of wrappers.
+	Name string `yaml:"name"`
+	// Match selects the invocations a wrapper in the list of wrappers is
+	// used for.
+	Match          matchRule `yaml:"match"`
+	ScriptTemplate string    `yaml:"script_template"`
+	// ScriptFile is a file holding the script template, an alternative to
+	// ScriptTemplate.
+	ScriptFile string `yaml:"script_file"`
+	// Partials is a directory of templates the script template can
+	// include with {{template "<file name>"}}.
+	Partials string `yaml:"partials"`
+	// PassArgs passes the arguments to the script instead of baking them
+	// into it, so they are available as "$@".
+	PassArgs bool `yaml:"pass_args"`
+	tmpfile  *os.File
+	// tmpdir is the private directory holding tmpfile.
+	tmpdir string
 }
 
-func (w *wrapper) Wrap(command string, args []string, verbose bool) (string, []string, error) {
-	if w.ScriptTemplate == "" {
+// WrapContext renders the script template for running command in the
+// context rc of the invocation. It returns the command and arguments to run
+// instead.
+func (w *wrapper) WrapContext(command string, rc runContext, verbose bool) (string, []string, error) {
+	args := rc.Args
+	if !w.hasScript() {
 		return command, args, nil
 	}
-	c := strings.Join(append([]string{command}, args...), " ")
-
-	data := struct {
-		TerraformBin string
-		Command      string
-		Verbose      bool
-	}{
-		TerraformBin: command,
-		Command:      c,
-		Verbose:      verbose,
+	script, err := w.render(command, rc, verbose)
+	if err != nil {
+		return command, args, err
 	}
 
-	var out bytes.Buffer
-	tmpl, err := template.New("wrapper").Parse(w.ScriptTemplate)
+	// rendered scripts may contain secrets, so they are written to a
+	// directory only the current user can access
+	base, err := runtimeDir()
 	if err != nil {
 		return command, args, err
 	}
-
-	err = tmpl.Execute(&out, data)
+	w.tmpdir, err = os.MkdirTemp(base, "run-*")
 	if err != nil {
 		return command, args, err
 	}
-
-	w.tmpfile, err = os.CreateTemp("", "wrapped.terraform.*.wtf")
+	w.tmpfile, err = os.CreateTemp(w.tmpdir, "wrapped.terraform.*.wtf")
 	if err != nil {
 		return command, args, err
 	}
 
-	if _, err := w.tmpfile.Write(out.Bytes()); err != nil {
+	if _, err := w.tmpfile.Write(script); err != nil {
 		w.tmpfile.Close()
 		return command, args, err
 	}
@@ -56,12 +69,172 @@ func (w *wrapper) Wrap(command string, args []string, verbose bool) (string, []s
 	if err != nil {
 		return command, args, err
 	}
-	return w.tmpfile.Name(), []string{}, nil
+	return w.tmpfile.Name(), w.scriptArgs(args), nil
 }
 
+// render executes the script template for running command.
+func (w *wrapper) render(command string, rc runContext, verbose bool) ([]byte, error) {
+	tmpl, err := w.template()
+	if err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, newTemplateData(command, rc, verbose)); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// scriptArgs returns the arguments the rendered script is run with.
+func (w *wrapper) scriptArgs(args []string) []string {
+	if w.PassArgs {
+		return args
+	}
+	return []string{}
+}
+
+// hasScript reports whether the wrapper runs a script instead of the tool.
+func (w *wrapper) hasScript() bool {
+	return w.ScriptTemplate != "" || w.ScriptFile != ""
+}
+
+// Cleanup removes the rendered script. It is safe to call Cleanup more than
+// once.
 func (w *wrapper) Cleanup() error {
+	if w.tmpdir != "" {
+		err := os.RemoveAll(w.tmpdir)
+		w.tmpdir, w.tmpfile = "", nil
+		return err
+	}
 	if w.tmpfile == nil {
 		return nil
 	}
-	return os.Remove(w.tmpfile.Name())
+	err := os.Remove(w.tmpfile.Name())
+	w.tmpfile = nil
+	return err
+}
+
+// runtimeDir returns a directory only accessible by the current user:
+// $XDG_RUNTIME_DIR/wtf, or a directory named after the user id in the
+// temporary directory.
+func runtimeDir() (string, error) {
+	dir := filepath.Join(os.TempDir(), "wtf")
+	if xdg := os.Getenv("XDG_RUNTIME_DIR"); xdg != "" {
+		dir = filepath.Join(xdg, "wtf")
+	} else if uid := os.Getuid(); uid >= 0 {
+		dir = filepath.Join(os.TempDir(), fmt.Sprintf("wtf-%d", uid))
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("runtime directory %s is not a directory", dir)
+	}
+	// fails if the directory was created by another user
+	if err := os.Chmod(dir, 0700); err != nil {
+		return "", fmt.Errorf("runtime directory %s is not private: %w", dir, err)
+	}
+	return dir, nil
+}
+
+// template parses the script template along with the partials. Templates
+// read from files are named after the file, so errors point to the file and
+// line.
+func (w *wrapper) template() (*template.Template, error) {
+	if w.ScriptTemplate != "" && w.ScriptFile != "" {
+		return nil, fmt.Errorf("wrapper '%s' sets both script_template and script_file", w.Name)
+	}
+
+	name, text := "wrapper", w.ScriptTemplate
+	if w.ScriptFile != "" {
+		data, err := os.ReadFile(w.ScriptFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read script template: %w", err)
+		}
+		name, text = w.ScriptFile, string(data)
+	}
+	tmpl, err := template.New(name).Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+
+	if w.Partials == "" {
+		return tmpl, nil
+	}
+	entries, err := os.ReadDir(w.Partials)
+	if err != nil {
+		return nil, fmt.Errorf("could not read partials: %w", err)
+	}
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		filename := filepath.Join(w.Partials, e.Name())
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := tmpl.New(e.Name()).Parse(string(data)); err != nil {
+			return nil, fmt.Errorf("%s: %w", filename, err)
+		}
+	}
+	return tmpl, nil
+}
+
+// resolvePaths expands the paths of the wrapper, relative paths are taken
+// relative to dir (the directory of the configuration file).
+func (w *wrapper) resolvePaths(dir string) error {
+	for _, p := range []*string{&w.ScriptFile, &w.Partials} {
+		if *p == "" {
+			continue
+		}
+		expanded, err := expandPath(*p)
+		if err != nil {
+			return err
+		}
+		if !filepath.IsAbs(expanded) {
+			expanded = filepath.Join(dir, expanded)
+		}
+		*p = expanded
+	}
+	return nil
+}
+
+// wrapperNone is the name of the wrapper running the tool directly.
+const wrapperNone = "none"
+
+// selectWrapper returns the wrapper with the given name, or if name is
+// empty the first wrapper matching the invocation. The single `wrapper` of
+// the configuration is used if no wrapper in the list matches.
+func (c *conf) selectWrapper(rc runContext, name string) (wrapper, error) {
+	if name == wrapperNone {
+		return wrapper{Name: wrapperNone}, nil
+	}
+	if name != "" {
+		for _, w := range c.Wrappers {
+			if w.Name == name {
+				return w, nil
+			}
+		}
+		return wrapper{}, fmt.Errorf("unknown wrapper '%s'", name)
+	}
+
+	for i, w := range c.Wrappers {
+		ok, err := w.Match.matches(rc)
+		if err != nil {
+			if w.Name == "" {
+				return wrapper{}, fmt.Errorf("wrapper #%d: %w", i+1, err)
+			}
+			return wrapper{}, fmt.Errorf("wrapper '%s': %w", w.Name, err)
+		}
+		if ok {
+			return w, nil
+		}
+	}
+	return c.Wrapper, nil
 }
@@ -2,6 +2,9 @@ package main
 
 import (
 	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
 	"strings"
 	"testing"
 )
@@ -122,6 +125,35 @@ func TestWrap(t *testing.T) {
 				}
 			},
 		},
+		{
+			name:     "template with QuotedCommand variable",
+			template: "#!/bin/sh\n{{.QuotedCommand}}",
+			command:  "/bin/terraform",
+			args:     []string{"plan", "-var", `tags={a="b c"}`, "-var", "owner=o'neil"},
+			checkContent: func(t *testing.T, content string) {
+				expected := `/bin/terraform plan -var 'tags={a="b c"}' -var 'owner=o'"'"'neil'`
+				if !strings.Contains(content, expected) {
+					t.Errorf("expected quoted command %q in template, got: %s", expected, content)
+				}
+			},
+		},
+		{
+			name:     "template with Args and shellquote",
+			template: "#!/bin/sh\n{{range .Args}}{{shellquote .}};{{end}} {{shellquote .Args}}",
+			command:  "/bin/terraform",
+			args:     []string{"apply", "a b", ""},
+			checkContent: func(t *testing.T, content string) {
+				if !strings.Contains(content, "apply;'a b';''; apply 'a b' ''") {
+					t.Errorf("expected quoted args in template, got: %s", content)
+				}
+			},
+		},
+		{
+			name:        "shellquote with unsupported type",
+			template:    "{{shellquote .Verbose}}",
+			command:     "/bin/terraform",
+			expectError: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -129,7 +161,7 @@ func TestWrap(t *testing.T) {
 			w := &wrapper{ScriptTemplate: tt.template}
 			defer func() { _ = w.Cleanup() }() // ensure cleanup after test
 
-			cmd, args, err := w.Wrap(tt.command, tt.args, tt.verbose)
+			cmd, args, err := w.WrapContext(tt.command, runContext{Args: tt.args}, tt.verbose)
 
 			if tt.expectError {
 				if err == nil {
@@ -220,9 +252,9 @@ func TestWrapperCleanup(t *testing.T) {
 
 			var tmpPath string
 			if tt.setupTmpfile {
-				// Create a real temp file via Wrap
+				// Create a real temp file via WrapContext
 				w.ScriptTemplate = "#!/bin/bash\necho test"
-				_, _, err := w.Wrap("/bin/test", nil, false)
+				_, _, err := w.WrapContext("/bin/test", runContext{}, false)
 				if err != nil {
 					t.Fatalf("setup failed: %v", err)
 				}
@@ -252,3 +284,272 @@ func TestWrapperCleanup(t *testing.T) {
 		})
 	}
 }
+
+func TestWrapShellRoundTrip(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no POSIX shell available")
+	}
+	args := []string{"plan", "-var", `tags={a="b c"}`, "it's", "", "$HOME", "*"}
+
+	tests := []struct {
+		name    string
+		wrapper wrapper
+	}{
+		{
+			name:    "quoted command",
+			wrapper: wrapper{ScriptTemplate: "#!/bin/sh\nfor a in {{shellquote .Args}}; do echo \"[$a]\"; done"},
+		},
+		{
+			name:    "pass args",
+			wrapper: wrapper{ScriptTemplate: "#!/bin/sh\nfor a in \"$@\"; do echo \"[$a]\"; done", PassArgs: true},
+		},
+	}
+
+	expected := ""
+	for _, a := range args {
+		expected += "[" + a + "]\n"
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := tt.wrapper
+			defer func() { _ = w.Cleanup() }()
+
+			cmd, wrappedArgs, err := w.WrapContext("/bin/terraform", runContext{Args: args}, false)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			out, err := exec.Command(cmd, wrappedArgs...).Output()
+			if err != nil {
+				t.Fatalf("script failed: %v", err)
+			}
+			if string(out) != expected {
+				t.Errorf("script printed\n%s\nwant\n%s", out, expected)
+			}
+		})
+	}
+}
+
+func TestSelectWrapper(t *testing.T) {
+	k := &conf{
+		Wrapper: wrapper{ScriptTemplate: "default"},
+		Wrappers: []wrapper{
+			{Name: "secrets", Match: matchRule{Path: "live", Subcommands: []string{"plan", "apply", "import"}}, ScriptTemplate: "secrets"},
+			{Name: "plain", Match: matchRule{Subcommands: []string{"fmt", "validate"}}},
+			{Name: "broken", Match: matchRule{Version: "~>"}},
+		},
+	}
+	v := mustVersions(t, "1.6.2")[0]
+
+	tests := []struct {
+		name          string
+		dir           string
+		args          []string
+		override      string
+		expected      string
+		errorContains string
+	}{
+		{name: "first match", dir: "/work/live/prod", args: []string{"apply"}, expected: "secrets"},
+		{name: "second match", dir: "/work/live/prod", args: []string{"fmt"}, expected: "plain"},
+		{name: "override", dir: "/work/live/prod", args: []string{"apply"}, override: "plain", expected: "plain"},
+		{name: "override with none", dir: "/work/live/prod", args: []string{"apply"}, override: "none", expected: "none"},
+		{name: "unknown override", dir: "/work/live/prod", args: []string{"apply"}, override: "other", errorContains: "unknown wrapper 'other'"},
+		{name: "invalid rule", dir: "/work/other", args: []string{"apply"}, errorContains: "wrapper 'broken'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, err := k.selectWrapper(runContext{Version: v, Args: tt.args, WorkingDir: tt.dir}, tt.override)
+			if tt.errorContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errorContains) {
+					t.Errorf("expected error containing %q, got %v", tt.errorContains, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if w.Name != tt.expected {
+				t.Errorf("selectWrapper() = %q, want %q", w.Name, tt.expected)
+			}
+		})
+	}
+
+	// without a matching wrapper in the list, the single wrapper is used
+	k.Wrappers = k.Wrappers[:2]
+	w, err := k.selectWrapper(runContext{Version: v, Args: []string{"apply"}, WorkingDir: "/work/other"}, "")
+	if err != nil || w.ScriptTemplate != "default" {
+		t.Errorf("selectWrapper() = %+v, %v, want the single wrapper", w, err)
+	}
+}
+
+func TestWrapScriptFile(t *testing.T) {
+	writeFile := func(t *testing.T, filename, content string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatalf("could not create dir: %v", err)
+		}
+		if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+			t.Fatalf("could not write file: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name          string
+		script        string
+		partials      map[string]string
+		inline        string
+		expected      string
+		errorContains string
+	}{
+		{
+			name:     "script with partials",
+			script:   "#!/bin/sh\n{{template \"env.sh\" .}}\n{{template \"footer\"}}\n{{.QuotedCommand}}",
+			partials: map[string]string{"env.sh": "export TF_VERSION={{.Version}}", "defs.sh": `{{define "footer"}}# footer{{end}}`},
+			expected: "#!/bin/sh\nexport TF_VERSION=1.6.2\n# footer\n/bin/terraform plan",
+		},
+		{
+			name:          "parse error names file and line",
+			script:        "#!/bin/sh\n\n{{if .Verbose}}",
+			errorContains: "script.sh:3:",
+		},
+		{
+			name:          "parse error in partial names file",
+			script:        "#!/bin/sh",
+			partials:      map[string]string{"broken.sh": "\n{{.Version"},
+			errorContains: "broken.sh:2:",
+		},
+		{
+			name:          "execution error names file and line",
+			script:        "#!/bin/sh\n{{.Unknown}}",
+			errorContains: "script.sh:2:",
+		},
+		{
+			name:          "script_template and script_file are exclusive",
+			script:        "#!/bin/sh",
+			inline:        "#!/bin/sh",
+			errorContains: "both script_template and script_file",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFile(t, filepath.Join(dir, "script.sh"), tt.script)
+			for name, content := range tt.partials {
+				writeFile(t, filepath.Join(dir, "partials", name), content)
+			}
+
+			w := &wrapper{Name: "test", ScriptTemplate: tt.inline, ScriptFile: "script.sh"}
+			if tt.partials != nil {
+				w.Partials = "partials"
+			}
+			if err := w.resolvePaths(dir); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			defer func() { _ = w.Cleanup() }()
+
+			rc := runContext{Version: mustVersions(t, "1.6.2")[0], Args: []string{"plan"}}
+			cmd, _, err := w.WrapContext("/bin/terraform", rc, false)
+			if tt.errorContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errorContains) {
+					t.Errorf("expected error containing %q, got %v", tt.errorContains, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			content, err := os.ReadFile(cmd)
+			if err != nil {
+				t.Fatalf("could not read script: %v", err)
+			}
+			if string(content) != tt.expected {
+				t.Errorf("script = %q, want %q", content, tt.expected)
+			}
+		})
+	}
+}
+
+func TestWrapPrivateRuntimeDir(t *testing.T) {
+	runtimeHome := t.TempDir()
+	t.Setenv("XDG_RUNTIME_DIR", runtimeHome)
+
+	w := &wrapper{ScriptTemplate: "#!/bin/sh\necho secret"}
+	cmd, _, err := w.WrapContext("/bin/terraform", runContext{}, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(cmd, filepath.Join(runtimeHome, "wtf")+string(filepath.Separator)) {
+		t.Errorf("script %s not in runtime dir %s", cmd, runtimeHome)
+	}
+	if runtime.GOOS != "windows" {
+		for _, dir := range []string{filepath.Join(runtimeHome, "wtf"), filepath.Dir(cmd)} {
+			info, err := os.Stat(dir)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if info.Mode().Perm() != 0700 {
+				t.Errorf("%s has mode %s, want 0700", dir, info.Mode().Perm())
+			}
+		}
+	}
+
+	if err := w.Cleanup(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Dir(cmd)); !os.IsNotExist(err) {
+		t.Error("expected run directory to be removed")
+	}
+	if err := w.Cleanup(); err != nil {
+		t.Errorf("second Cleanup() failed: %v", err)
+	}
+}
+
+func TestRunCleansUp(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("wrapper scripts require a POSIX shell")
+	}
+	runtimeHome := t.TempDir()
+	t.Setenv("XDG_RUNTIME_DIR", runtimeHome)
+	store, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tf := &Terraform{tool: knownTools["terraform"], store: store}
+	rc := runContext{Version: mustVersions(t, "1.6.2")[0], WorkingDir: t.TempDir()}
+
+	tests := []struct {
+		name        string
+		template    string
+		exitCode    int
+		expectError bool
+	}{
+		{name: "success", template: "#!/bin/sh\nexit 0"},
+		{name: "failing tool", template: "#!/bin/sh\nexit 3", exitCode: 3},
+		{name: "tool cannot be started", template: "not a script", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state, err := tf.Run(rc, wrapper{ScriptTemplate: tt.template})
+			if tt.expectError {
+				if err == nil {
+					t.Error("expected error, got nil")
+				}
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			} else if state.ExitCode() != tt.exitCode {
+				t.Errorf("exit code = %d, want %d", state.ExitCode(), tt.exitCode)
+			}
+
+			entries, err := os.ReadDir(filepath.Join(runtimeHome, "wtf"))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(entries) != 0 {
+				t.Errorf("expected runtimeHome dir to be empty, found %d entries", len(entries))
+			}
+		})
+	}
+}